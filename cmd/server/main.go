@@ -9,11 +9,18 @@ import (
 	"log"
 	"service-manager/internal/backend/server"
 	"service-manager/internal/backend/utils"
+	"service-manager/internal/logstore"
+	"service-manager/internal/manager"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 func main() {
+	// Services needing settings exec.Cmd cannot apply are started through the
+	// manager's own executable, which then never gets past this call
+	manager.RunLauncherIfRequested()
+
 	err := godotenv.Load()
 	if err != nil {
 		log.Println("No .env file found, using system environment variables")
@@ -22,8 +29,28 @@ func main() {
 	PORT := utils.GetEnv("PORT", "8080")
 	LOGS_DIR := utils.GetEnv("LOGS_DIR", "data/logs")
 	SERVICES_DATA := utils.GetEnv("SERVICES_DATA", "data/services_data.json")
+	AUTOSTART_STAGGER_SECONDS := utils.GetEnvFloat("AUTOSTART_STAGGER_SECONDS", 0)
+	// Cgroup v2 slice of services with resource limits, relative to the cgroup mount
+	CGROUP_SLICE := utils.GetEnv("CGROUP_SLICE", "service-manager.slice")
+
+	// Default global log rotation: 10 MiB files, every rotated segment is kept
+	// unless retention is configured
+	logRotation := logstore.RotationPolicy{
+		MaxSizeBytes:  utils.GetEnvInt("LOG_MAX_SIZE_BYTES", 10*1024*1024),
+		MaxAgeSeconds: utils.GetEnvFloat("LOG_MAX_AGE_SECONDS", 0),
+		MaxFiles:      int(utils.GetEnvInt("LOG_MAX_FILES", 0)),
+		MaxTotalBytes: utils.GetEnvInt("LOG_MAX_TOTAL_BYTES", 0),
+	}
 
-	srv, err := server.NewServer(LOGS_DIR, SERVICES_DATA, HOST, PORT)
+	srv, err := server.NewServer(
+		LOGS_DIR,
+		SERVICES_DATA,
+		HOST,
+		PORT,
+		time.Duration(AUTOSTART_STAGGER_SECONDS*float64(time.Second)),
+		logRotation,
+		CGROUP_SLICE,
+	)
 	if err != nil {
 		log.Println("create server: ", err)
 	}
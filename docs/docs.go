@@ -39,6 +39,133 @@ const docTemplate = `{
                 }
             }
         },
+        "/logs/{serviceID}": {
+            "get": {
+                "description": "Returns a page of the log lines of a service matching the filters, oldest first, searching through rotated and compressed segments. Without a cursor the newest matching lines are returned. Lines logged before sequence numbers were recorded are not searched.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "logs"
+                ],
+                "summary": "Search the logs of a service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Service ID",
+                        "name": "serviceID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "stdout",
+                            "stderr",
+                            "both"
+                        ],
+                        "type": "string",
+                        "description": "Stream to search, both by default",
+                        "name": "stream",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only lines captured at or after this RFC 3339 time",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only lines captured before this RFC 3339 time",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Text the lines must contain, a regular expression if regex is set",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Match q as a regular expression",
+                        "name": "regex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Match q case sensitively",
+                        "name": "case_sensitive",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Cursor, returns the oldest matching lines after this sequence number",
+                        "name": "after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Cursor, returns the newest matching lines before this sequence number",
+                        "name": "before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of lines, 100 by default and at most 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "keep",
+                            "strip",
+                            "html"
+                        ],
+                        "type": "string",
+                        "description": "ANSI escape sequences are kept, stripped or turned into HTML, keep by default",
+                        "name": "ansi",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "keep",
+                            "last"
+                        ],
+                        "type": "string",
+                        "description": "Carriage returns are kept, or only the text written last over a line is returned, keep by default",
+                        "name": "cr",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.SearchLogsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/manager/metrics": {
             "post": {
                 "description": "Get the metrics such as cpu percentage, ram usage and uptime.",
@@ -252,9 +379,47 @@ const docTemplate = `{
                 }
             }
         },
+        "/manager/services/{serviceID}/runs": {
+            "get": {
+                "description": "Returns the recorded runs of a service, most recent first, with exit code, terminating signal, exit reason and the last stderr lines.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "manager"
+                ],
+                "summary": "Get the run history of a service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Service ID",
+                        "name": "serviceID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/manager.RunRecord"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/manager/start": {
             "post": {
-                "description": "Starts a registered service.",
+                "description": "Starts a registered service after the services it depends on.",
                 "consumes": [
                     "application/json"
                 ],
@@ -301,9 +466,29 @@ const docTemplate = `{
                 }
             }
         },
+        "/manager/startup": {
+            "get": {
+                "description": "Returns which autostart services came up when the manager booted and which failed. finished_at is zero while services are still being started.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "manager"
+                ],
+                "summary": "Get the autostart summary",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/manager.StartupSummary"
+                        }
+                    }
+                }
+            }
+        },
         "/manager/stop": {
             "post": {
-                "description": "Stops a running service.",
+                "description": "Stops a running service after the services depending on it. The stop signal is sent first and the process group is killed once the timeout expires.",
                 "consumes": [
                     "application/json"
                 ],
@@ -316,12 +501,12 @@ const docTemplate = `{
                 "summary": "Stop a service",
                 "parameters": [
                     {
-                        "description": "Service ID",
+                        "description": "Service ID and optional timeout override",
                         "name": "service",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.ServiceIDRequest"
+                            "$ref": "#/definitions/api.StopServiceRequest"
                         }
                     }
                 ],
@@ -330,9 +515,7 @@ const docTemplate = `{
                         "description": "OK",
                         "schema": {
                             "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "additionalProperties": true
                         }
                     },
                     "400": {
@@ -350,16 +533,69 @@ const docTemplate = `{
                 }
             }
         },
-        "/stream/stderr/{serviceID}": {
+        "/manager/tty/resize": {
+            "post": {
+                "description": "Sets the window size of the pseudo-terminal of a running service registered with tty, which gets SIGWINCH. The next runs start with the size the service was registered with.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "manager"
+                ],
+                "summary": "Resize the terminal of a service",
+                "parameters": [
+                    {
+                        "description": "Service ID and window size",
+                        "name": "service",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ResizeTTYRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stream/combined/{serviceID}": {
             "get": {
-                "description": "Streams the standard error log of a service using Server-Sent Events (SSE).",
+                "description": "Streams the standard output and standard error logs of a service interleaved in capture order using Server-Sent Events (SSE). Each line carries the stream it was captured from.",
                 "produces": [
                     "text/event-stream"
                 ],
                 "tags": [
                     "stream"
                 ],
-                "summary": "Stream service stderr logs",
+                "summary": "Stream service stdout and stderr logs",
                 "parameters": [
                     {
                         "type": "string",
@@ -367,11 +603,82 @@ const docTemplate = `{
                         "name": "serviceID",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Sequence number of the last line received, the stream resumes after it",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Same as the Last-Event-ID header, for clients that cannot set it",
+                        "name": "last_event_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of last lines sent initially, 10000 by default",
+                        "name": "tail",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only send initial lines captured at or after this RFC 3339 time",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only send lines matching this regular expression",
+                        "name": "include",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Do not send lines matching this regular expression",
+                        "name": "exclude",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "trace",
+                            "debug",
+                            "info",
+                            "warn",
+                            "error",
+                            "fatal"
+                        ],
+                        "type": "string",
+                        "description": "Only send lines with a detected level at least as severe",
+                        "name": "min_level",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "keep",
+                            "strip",
+                            "html"
+                        ],
+                        "type": "string",
+                        "description": "ANSI escape sequences are kept, stripped or turned into HTML, keep by default",
+                        "name": "ansi",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "keep",
+                            "last"
+                        ],
+                        "type": "string",
+                        "description": "Carriage returns are kept, or only the text written last over a line is sent, keep by default",
+                        "name": "cr",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "SSE stream of log data",
+                        "description": "SSE stream of log data, lines are sent as api.LogLine",
                         "schema": {
                             "$ref": "#/definitions/api.StreamMessage"
                         }
@@ -379,16 +686,16 @@ const docTemplate = `{
                 }
             }
         },
-        "/stream/stdout/{serviceID}": {
+        "/stream/stderr/{serviceID}": {
             "get": {
-                "description": "Streams the standard output log of a service using Server-Sent Events (SSE).",
+                "description": "Streams the standard error log of a service using Server-Sent Events (SSE).",
                 "produces": [
                     "text/event-stream"
                 ],
                 "tags": [
                     "stream"
                 ],
-                "summary": "Stream service stdout logs",
+                "summary": "Stream service stderr logs",
                 "parameters": [
                     {
                         "type": "string",
@@ -396,49 +703,260 @@ const docTemplate = `{
                         "name": "serviceID",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Sequence number of the last line received, the stream resumes after it",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Same as the Last-Event-ID header, for clients that cannot set it",
+                        "name": "last_event_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of last lines sent initially, 10000 by default",
+                        "name": "tail",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only send initial lines captured at or after this RFC 3339 time",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only send lines matching this regular expression",
+                        "name": "include",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Do not send lines matching this regular expression",
+                        "name": "exclude",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "trace",
+                            "debug",
+                            "info",
+                            "warn",
+                            "error",
+                            "fatal"
+                        ],
+                        "type": "string",
+                        "description": "Only send lines with a detected level at least as severe",
+                        "name": "min_level",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "keep",
+                            "strip",
+                            "html"
+                        ],
+                        "type": "string",
+                        "description": "ANSI escape sequences are kept, stripped or turned into HTML, keep by default",
+                        "name": "ansi",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "keep",
+                            "last"
+                        ],
+                        "type": "string",
+                        "description": "Carriage returns are kept, or only the text written last over a line is sent, keep by default",
+                        "name": "cr",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "SSE stream of log data",
+                        "description": "SSE stream of log data, lines are sent as api.LogLine",
                         "schema": {
                             "$ref": "#/definitions/api.StreamMessage"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "api.ErrorResponse": {
-            "type": "object",
-            "properties": {
-                "details": {
-                    "type": "string"
-                },
-                "error_message": {
-                    "type": "string"
-                }
-            }
-        },
-        "api.NetworkInfo": {
-            "type": "object",
-            "properties": {
-                "ip": {
-                    "type": "string"
-                },
-                "port": {
-                    "type": "integer"
-                }
-            }
         },
-        "api.RegisterServiceRequest": {
+        "/stream/stdout/{serviceID}": {
+            "get": {
+                "description": "Streams the standard output log of a service using Server-Sent Events (SSE).",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "stream"
+                ],
+                "summary": "Stream service stdout logs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Service ID",
+                        "name": "serviceID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Sequence number of the last line received, the stream resumes after it",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Same as the Last-Event-ID header, for clients that cannot set it",
+                        "name": "last_event_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of last lines sent initially, 10000 by default",
+                        "name": "tail",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only send initial lines captured at or after this RFC 3339 time",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only send lines matching this regular expression",
+                        "name": "include",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Do not send lines matching this regular expression",
+                        "name": "exclude",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "trace",
+                            "debug",
+                            "info",
+                            "warn",
+                            "error",
+                            "fatal"
+                        ],
+                        "type": "string",
+                        "description": "Only send lines with a detected level at least as severe",
+                        "name": "min_level",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "keep",
+                            "strip",
+                            "html"
+                        ],
+                        "type": "string",
+                        "description": "ANSI escape sequences are kept, stripped or turned into HTML, keep by default",
+                        "name": "ansi",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "keep",
+                            "last"
+                        ],
+                        "type": "string",
+                        "description": "Carriage returns are kept, or only the text written last over a line is sent, keep by default",
+                        "name": "cr",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "SSE stream of log data, lines are sent as api.LogLine",
+                        "schema": {
+                            "$ref": "#/definitions/api.StreamMessage"
+                        }
+                    }
+                }
+            }
+        },
+        "/stream/ws": {
+            "get": {
+                "description": "Upgrades to a WebSocket connection carrying several log subscriptions. The client sends api.WebSocketRequest messages to subscribe to the logs of a service, unsubscribe, pause or resume a subscription, and start or stop a service. Every request is answered with an event_ack or event_error message carrying its request_id. Log messages are the ones of the SSE endpoints, carrying their subscription id. Status changes of every service are sent as event_status messages.",
+                "tags": [
+                    "stream"
+                ],
+                "summary": "Multiplex log streams and control services over WebSocket",
+                "responses": {
+                    "101": {
+                        "description": "WebSocket messages",
+                        "schema": {
+                            "$ref": "#/definitions/api.StreamMessage"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "details": {
+                    "type": "string"
+                },
+                "error_message": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.LogLine": {
+            "type": "object",
+            "properties": {
+                "line": {
+                    "type": "string"
+                },
+                "seq": {
+                    "type": "integer"
+                },
+                "stream": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.NetworkInfo": {
+            "type": "object",
+            "properties": {
+                "ip": {
+                    "type": "string"
+                },
+                "port": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.RegisterServiceRequest": {
             "type": "object",
             "required": [
                 "command_name",
                 "service_name"
             ],
             "properties": {
+                "autostart": {
+                    "type": "boolean"
+                },
+                "clean_env": {
+                    "type": "boolean"
+                },
                 "command_args": {
                     "type": "array",
                     "items": {
@@ -448,31 +966,215 @@ const docTemplate = `{
                 "command_name": {
                     "type": "string"
                 },
+                "depends_on": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/manager.Dependency"
+                    }
+                },
+                "env": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "env_files": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "execute_directory": {
                     "type": "string"
                 },
+                "health_check": {
+                    "$ref": "#/definitions/manager.HealthCheck"
+                },
+                "log_rotation": {
+                    "$ref": "#/definitions/logstore.RotationPolicy"
+                },
+                "long_lines": {
+                    "$ref": "#/definitions/manager.LongLines"
+                },
+                "multiline": {
+                    "$ref": "#/definitions/manager.Multiline"
+                },
+                "resources": {
+                    "$ref": "#/definitions/manager.ResourceLimits"
+                },
+                "restart_policy": {
+                    "$ref": "#/definitions/manager.RestartPolicy"
+                },
+                "rlimits": {
+                    "description": "Limits keyed by resource name, such as \"nofile\", \"core\" or \"nproc\"",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/manager.Rlimit"
+                    }
+                },
+                "run_as_group": {
+                    "type": "string"
+                },
+                "run_as_user": {
+                    "type": "string"
+                },
+                "sandbox": {
+                    "$ref": "#/definitions/manager.Sandbox"
+                },
+                "scheduling": {
+                    "$ref": "#/definitions/manager.Scheduling"
+                },
                 "service_name": {
                     "type": "string"
+                },
+                "stop": {
+                    "$ref": "#/definitions/manager.StopSpec"
+                },
+                "supplementary_groups": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "tty": {
+                    "description": "Run in a pseudo-terminal, with the window size of tty_size",
+                    "type": "boolean"
+                },
+                "tty_size": {
+                    "$ref": "#/definitions/manager.TTYSize"
+                },
+                "umask": {
+                    "description": "Octal file mode creation mask, such as \"027\"",
+                    "type": "string"
+                }
+            }
+        },
+        "api.ResizeTTYRequest": {
+            "type": "object",
+            "required": [
+                "cols",
+                "rows"
+            ],
+            "properties": {
+                "cols": {
+                    "type": "integer"
+                },
+                "rows": {
+                    "type": "integer"
+                },
+                "service_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.SearchLogsResponse": {
+            "type": "object",
+            "properties": {
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.LogLine"
+                    }
+                },
+                "next_cursor": {
+                    "description": "Passed as after, returns the next page. Absent when there is none.",
+                    "type": "integer"
+                },
+                "prev_cursor": {
+                    "description": "Passed as before, returns the previous page. Absent when there is none.",
+                    "type": "integer"
                 }
             }
         },
         "api.ServiceData": {
             "type": "object",
             "properties": {
+                "autostart": {
+                    "type": "boolean"
+                },
                 "cmd": {
                     "$ref": "#/definitions/manager.Command"
                 },
+                "depends_on": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/manager.Dependency"
+                    }
+                },
                 "execute_directory": {
                     "type": "string"
                 },
+                "health": {
+                    "$ref": "#/definitions/manager.HealthState"
+                },
+                "health_check": {
+                    "$ref": "#/definitions/manager.HealthCheck"
+                },
                 "id": {
                     "type": "string"
                 },
                 "is_running": {
                     "type": "boolean"
                 },
+                "long_lines": {
+                    "$ref": "#/definitions/manager.LongLines"
+                },
+                "multiline": {
+                    "$ref": "#/definitions/manager.Multiline"
+                },
                 "name": {
                     "type": "string"
+                },
+                "next_retry_at": {
+                    "type": "string"
+                },
+                "resources": {
+                    "$ref": "#/definitions/manager.ResourceLimits"
+                },
+                "restart_attempts": {
+                    "type": "integer"
+                },
+                "restart_policy": {
+                    "$ref": "#/definitions/manager.RestartPolicy"
+                },
+                "rlimits": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/manager.Rlimit"
+                    }
+                },
+                "run_as_group": {
+                    "type": "string"
+                },
+                "run_as_user": {
+                    "type": "string"
+                },
+                "sandbox": {
+                    "$ref": "#/definitions/manager.Sandbox"
+                },
+                "scheduling": {
+                    "$ref": "#/definitions/manager.Scheduling"
+                },
+                "status": {
+                    "$ref": "#/definitions/manager.ServiceStatus"
+                },
+                "stop": {
+                    "$ref": "#/definitions/manager.StopSpec"
+                },
+                "supplementary_groups": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "tty": {
+                    "type": "boolean"
+                },
+                "tty_size": {
+                    "$ref": "#/definitions/manager.TTYSize"
+                },
+                "umask": {
+                    "type": "string"
                 }
             }
         },
@@ -498,28 +1200,93 @@ const docTemplate = `{
                 }
             }
         },
+        "api.StopServiceRequest": {
+            "type": "object",
+            "properties": {
+                "service_id": {
+                    "type": "string"
+                },
+                "timeout_seconds": {
+                    "description": "TimeoutSeconds overrides the stop timeout of the service for this call",
+                    "type": "number",
+                    "minimum": 0
+                }
+            }
+        },
         "api.StreamEvent": {
             "type": "string",
             "enum": [
                 "event_initial",
                 "event_append",
-                "event_error"
+                "event_error",
+                "event_gap",
+                "event_status",
+                "event_ack"
             ],
             "x-enum-varnames": [
                 "EVENT_INITIAL",
                 "EVENT_APPEND",
-                "EVENT_ERROR"
+                "EVENT_ERROR",
+                "EVENT_GAP",
+                "EVENT_STATUS",
+                "EVENT_ACK"
             ]
         },
         "api.StreamMessage": {
             "type": "object",
             "properties": {
                 "data": {},
+                "request_id": {
+                    "description": "Request the message answers, only set over WebSocket connections",
+                    "type": "string"
+                },
+                "subscription": {
+                    "description": "Subscription the message belongs to, only set over WebSocket connections",
+                    "type": "string"
+                },
                 "type": {
                     "$ref": "#/definitions/api.StreamEvent"
                 }
             }
         },
+        "logstore.RotationPolicy": {
+            "type": "object",
+            "properties": {
+                "max_age_seconds": {
+                    "description": "MaxAgeSeconds rotates the file once it is older than this",
+                    "type": "number"
+                },
+                "max_files": {
+                    "description": "MaxFiles is the number of rotated segments kept",
+                    "type": "integer"
+                },
+                "max_size_bytes": {
+                    "description": "MaxSizeBytes rotates the file once it grows past this size",
+                    "type": "integer"
+                },
+                "max_total_bytes": {
+                    "description": "MaxTotalBytes is the byte budget of the rotated segments",
+                    "type": "integer"
+                }
+            }
+        },
+        "manager.AutostartResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "service_id": {
+                    "type": "string"
+                },
+                "started": {
+                    "type": "boolean"
+                }
+            }
+        },
         "manager.Command": {
             "type": "object",
             "properties": {
@@ -535,6 +1302,458 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "manager.Dependency": {
+            "type": "object",
+            "properties": {
+                "condition": {
+                    "description": "Condition is \"started\" (default), \"healthy\" or \"listening\"",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/manager.DependencyCondition"
+                        }
+                    ]
+                },
+                "service_id": {
+                    "type": "string"
+                },
+                "timeout_seconds": {
+                    "description": "TimeoutSeconds bounds the wait for the condition, defaults to 60 seconds",
+                    "type": "number"
+                }
+            }
+        },
+        "manager.DependencyCondition": {
+            "type": "string",
+            "enum": [
+                "started",
+                "healthy",
+                "listening"
+            ],
+            "x-enum-varnames": [
+                "DEPENDENCY_STARTED",
+                "DEPENDENCY_HEALTHY",
+                "DEPENDENCY_LISTENING"
+            ]
+        },
+        "manager.ExitReason": {
+            "type": "string",
+            "enum": [
+                "user_stop",
+                "crash",
+                "completed",
+                "start_failed",
+                "unhealthy",
+                "oom_killed"
+            ],
+            "x-enum-varnames": [
+                "EXIT_USER_STOP",
+                "EXIT_CRASH",
+                "EXIT_COMPLETED",
+                "EXIT_START_FAILED",
+                "EXIT_UNHEALTHY",
+                "EXIT_OOM_KILLED"
+            ]
+        },
+        "manager.HealthCheck": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "description": "Address is the host:port tcp checks connect to",
+                    "type": "string"
+                },
+                "command": {
+                    "description": "Command is run in the execute directory with the service environment by exec checks",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/manager.Command"
+                        }
+                    ]
+                },
+                "expected_exit_code": {
+                    "description": "ExpectedExitCode is the exit code expected by exec checks",
+                    "type": "integer"
+                },
+                "expected_status": {
+                    "description": "ExpectedStatus is the status code expected by http checks, defaults to any 2xx",
+                    "type": "integer"
+                },
+                "failure_threshold": {
+                    "description": "FailureThreshold is the number of consecutive failures before the service\nis unhealthy, defaults to 3",
+                    "type": "integer"
+                },
+                "interval_seconds": {
+                    "description": "IntervalSeconds is the time between two checks, defaults to 10 seconds",
+                    "type": "number"
+                },
+                "restart_on_unhealthy": {
+                    "description": "RestartOnUnhealthy restarts the process once it is unhealthy",
+                    "type": "boolean"
+                },
+                "start_period_seconds": {
+                    "description": "StartPeriodSeconds is the time after start during which failures are not counted",
+                    "type": "number"
+                },
+                "timeout_seconds": {
+                    "description": "TimeoutSeconds is the time a single check may take, defaults to 5 seconds",
+                    "type": "number"
+                },
+                "type": {
+                    "description": "Type is \"http\", \"tcp\" or \"exec\", empty disables health checks",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/manager.HealthCheckType"
+                        }
+                    ]
+                },
+                "url": {
+                    "description": "URL is requested with GET by http checks",
+                    "type": "string"
+                }
+            }
+        },
+        "manager.HealthCheckType": {
+            "type": "string",
+            "enum": [
+                "http",
+                "tcp",
+                "exec"
+            ],
+            "x-enum-varnames": [
+                "HEALTH_CHECK_HTTP",
+                "HEALTH_CHECK_TCP",
+                "HEALTH_CHECK_EXEC"
+            ]
+        },
+        "manager.HealthState": {
+            "type": "object",
+            "properties": {
+                "consecutive_failures": {
+                    "type": "integer"
+                },
+                "last_check_at": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                }
+            }
+        },
+        "manager.IOClass": {
+            "type": "string",
+            "enum": [
+                "realtime",
+                "best-effort",
+                "idle"
+            ],
+            "x-enum-varnames": [
+                "IO_CLASS_REALTIME",
+                "IO_CLASS_BEST_EFFORT",
+                "IO_CLASS_IDLE"
+            ]
+        },
+        "manager.LongLineMode": {
+            "type": "string",
+            "enum": [
+                "split",
+                "truncate"
+            ],
+            "x-enum-varnames": [
+                "LONG_LINES_SPLIT",
+                "LONG_LINES_TRUNCATE"
+            ]
+        },
+        "manager.LongLines": {
+            "type": "object",
+            "properties": {
+                "max_bytes": {
+                    "description": "MaxBytes is the maximum length of a captured line, defaults to 64 KiB",
+                    "type": "integer"
+                },
+                "mode": {
+                    "description": "Mode is \"split\" or \"truncate\", defaults to \"split\"",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/manager.LongLineMode"
+                        }
+                    ]
+                }
+            }
+        },
+        "manager.Multiline": {
+            "type": "object",
+            "properties": {
+                "flush_timeout_seconds": {
+                    "description": "FlushTimeoutSeconds is how long a record waits for more lines before it is\ncaptured, defaults to 0.5 seconds",
+                    "type": "number"
+                },
+                "max_lines": {
+                    "description": "MaxLines is the maximum number of lines of a record, defaults to 500",
+                    "type": "integer"
+                },
+                "preset": {
+                    "description": "Preset selects built-in rules, \"java\", \"python\", \"go\" or \"node\"",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/manager.MultilinePreset"
+                        }
+                    ]
+                },
+                "start_pattern": {
+                    "description": "StartPattern is a regular expression matching the first line of a record,\nlines that do not match are appended to the current record",
+                    "type": "string"
+                }
+            }
+        },
+        "manager.MultilinePreset": {
+            "type": "string",
+            "enum": [
+                "java",
+                "python",
+                "go",
+                "node"
+            ],
+            "x-enum-varnames": [
+                "MULTILINE_JAVA",
+                "MULTILINE_PYTHON",
+                "MULTILINE_GO",
+                "MULTILINE_NODE"
+            ]
+        },
+        "manager.ResourceLimits": {
+            "type": "object",
+            "properties": {
+                "cpu_quota": {
+                    "description": "CPUQuota is the number of CPUs the service can use, such as 0.5 or 2",
+                    "type": "number"
+                },
+                "cpu_weight": {
+                    "description": "CPUWeight is the share of CPU time under contention, from 1 to 10000, 100 by default",
+                    "type": "integer"
+                },
+                "io_weight": {
+                    "description": "IOWeight is the share of IO time under contention, from 1 to 10000, 100 by default",
+                    "type": "integer"
+                },
+                "memory_high_bytes": {
+                    "description": "MemoryHighBytes is the memory usage above which processes are throttled",
+                    "type": "integer"
+                },
+                "memory_max_bytes": {
+                    "description": "MemoryMaxBytes is the hard memory limit, processes going over it are OOM killed",
+                    "type": "integer"
+                },
+                "pids_max": {
+                    "description": "PidsMax is the maximum number of processes and threads",
+                    "type": "integer"
+                }
+            }
+        },
+        "manager.RestartMode": {
+            "type": "string",
+            "enum": [
+                "never",
+                "on-failure",
+                "always"
+            ],
+            "x-enum-varnames": [
+                "RESTART_NEVER",
+                "RESTART_ON_FAILURE",
+                "RESTART_ALWAYS"
+            ]
+        },
+        "manager.RestartPolicy": {
+            "type": "object",
+            "properties": {
+                "initial_delay_seconds": {
+                    "description": "InitialDelaySeconds is the delay before the first restart, defaults to 1 second",
+                    "type": "number"
+                },
+                "max_delay_seconds": {
+                    "description": "MaxDelaySeconds caps the delay between restarts, defaults to 60 seconds",
+                    "type": "number"
+                },
+                "max_retries": {
+                    "description": "MaxRetries is the number of consecutive restarts before giving up, 0 means unlimited",
+                    "type": "integer"
+                },
+                "mode": {
+                    "description": "Mode is one of \"never\" (default), \"on-failure\" or \"always\"",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/manager.RestartMode"
+                        }
+                    ]
+                },
+                "multiplier": {
+                    "description": "Multiplier is applied to the delay after every restart, defaults to 2",
+                    "type": "number"
+                }
+            }
+        },
+        "manager.Rlimit": {
+            "type": "object",
+            "properties": {
+                "hard": {
+                    "description": "Hard is the ceiling the process can raise Soft to, defaults to Soft",
+                    "type": "integer"
+                },
+                "soft": {
+                    "type": "integer"
+                }
+            }
+        },
+        "manager.RunRecord": {
+            "type": "object",
+            "properties": {
+                "end_time": {
+                    "type": "string"
+                },
+                "error": {
+                    "description": "Error is the error that ended the run, if any",
+                    "type": "string"
+                },
+                "exit_code": {
+                    "description": "ExitCode is nil when the process was terminated by a signal or never started",
+                    "type": "integer"
+                },
+                "killed": {
+                    "description": "Killed is true when the process did not stop within its grace period",
+                    "type": "boolean"
+                },
+                "reason": {
+                    "$ref": "#/definitions/manager.ExitReason"
+                },
+                "signal": {
+                    "description": "Signal is the name of the signal that terminated the process, if any",
+                    "type": "string"
+                },
+                "start_time": {
+                    "type": "string"
+                },
+                "stderr_tail": {
+                    "description": "StderrTail holds the last lines written to stderr before the exit",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "manager.Sandbox": {
+            "type": "object",
+            "properties": {
+                "no_network": {
+                    "description": "NoNetwork runs the service in a network namespace with only a loopback\ninterface",
+                    "type": "boolean"
+                },
+                "no_new_privileges": {
+                    "description": "NoNewPrivileges stops the service from gaining privileges by executing\nsetuid programs or programs with file capabilities",
+                    "type": "boolean"
+                },
+                "private_pid": {
+                    "description": "PrivatePID runs the service in a PID namespace with its own /proc, in\nwhich only its processes are visible",
+                    "type": "boolean"
+                },
+                "read_only_paths": {
+                    "description": "ReadOnlyPaths are absolute paths mounted read-only, with what is mounted\nbelow them, in a mount namespace of the service",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "manager.Scheduling": {
+            "type": "object",
+            "properties": {
+                "cpu_affinity": {
+                    "description": "CPUAffinity is the list of CPUs the service can run on",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "io_class": {
+                    "description": "IOClass is \"realtime\", \"best-effort\" or \"idle\"",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/manager.IOClass"
+                        }
+                    ]
+                },
+                "io_priority": {
+                    "description": "IOPriority is the priority within the realtime and best-effort\nclasses, from 0 (highest) to 7 (lowest), 4 by default",
+                    "type": "integer"
+                },
+                "nice": {
+                    "description": "Nice is the CPU priority, from -20 (highest) to 19 (lowest)",
+                    "type": "integer"
+                }
+            }
+        },
+        "manager.ServiceStatus": {
+            "type": "string",
+            "enum": [
+                "service_unknown",
+                "service_running",
+                "service_stopped",
+                "service_restarting",
+                "service_starting",
+                "service_healthy",
+                "service_unhealthy"
+            ],
+            "x-enum-varnames": [
+                "SERVICE_UNKNOWN",
+                "SERVICE_RUNNING",
+                "SERVICE_STOPPED",
+                "SERVICE_RESTARTING",
+                "SERVICE_STARTING",
+                "SERVICE_HEALTHY",
+                "SERVICE_UNHEALTHY"
+            ]
+        },
+        "manager.StartupSummary": {
+            "type": "object",
+            "properties": {
+                "finished_at": {
+                    "description": "FinishedAt is zero while services are still being started",
+                    "type": "string"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/manager.AutostartResult"
+                    }
+                },
+                "started_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "manager.StopSpec": {
+            "type": "object",
+            "properties": {
+                "signal": {
+                    "description": "Signal is sent first, defaults to \"SIGTERM\". Ignored on Windows",
+                    "type": "string"
+                },
+                "timeout_seconds": {
+                    "description": "TimeoutSeconds is the grace period before the process group is killed, defaults to 10 seconds",
+                    "type": "number"
+                }
+            }
+        },
+        "manager.TTYSize": {
+            "type": "object",
+            "properties": {
+                "cols": {
+                    "type": "integer"
+                },
+                "rows": {
+                    "type": "integer"
+                }
+            }
         }
     }
 }`
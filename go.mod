@@ -3,11 +3,12 @@ module service-manager
 go 1.25.3
 
 require (
-	github.com/fsnotify/fsnotify v1.9.0
+	github.com/creack/pty v1.1.24
 	github.com/gin-contrib/cors v1.7.6
 	github.com/gin-gonic/gin v1.11.0
 	github.com/go-playground/validator/v10 v10.28.0
 	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/joho/godotenv v1.5.1
 	github.com/shirou/gopsutil/v3 v3.24.5
 	github.com/swaggo/files v1.0.1
@@ -0,0 +1,26 @@
+package api
+
+import "time"
+
+// SearchLogsRequest holds the query parameters of a log search. Without a
+// cursor the newest matching lines are returned.
+type SearchLogsRequest struct {
+	Stream        string     `form:"stream" binding:"omitempty,oneof=stdout stderr both"`
+	From          *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To            *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Query         string     `form:"q"`
+	Regex         bool       `form:"regex"`
+	CaseSensitive bool       `form:"case_sensitive"`
+	After         *uint64    `form:"after"`
+	Before        *uint64    `form:"before" binding:"excluded_with=After"`
+	Limit         int        `form:"limit" binding:"omitempty,gte=1,lte=1000"`
+	LineFormat
+}
+
+type SearchLogsResponse struct {
+	Lines []LogLine `json:"lines"`
+	// Passed as before, returns the previous page. Absent when there is none.
+	PrevCursor *uint64 `json:"prev_cursor,omitempty"`
+	// Passed as after, returns the next page. Absent when there is none.
+	NextCursor *uint64 `json:"next_cursor,omitempty"`
+}
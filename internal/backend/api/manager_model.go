@@ -1,12 +1,53 @@
 package api
 
+import (
+	"service-manager/internal/logstore"
+	"service-manager/internal/manager"
+)
+
 type RegisterServiceRequest struct {
-	ServiceName      string   `json:"service_name" binding:"required"`
-	CommandName      string   `json:"command_name" binding:"required"`
-	CommandArgs      []string `json:"command_args"`
-	ExecuteDirectory string   `json:"execute_directory"`
+	ServiceName         string                  `json:"service_name" binding:"required"`
+	CommandName         string                  `json:"command_name" binding:"required"`
+	CommandArgs         []string                `json:"command_args"`
+	ExecuteDirectory    string                  `json:"execute_directory"`
+	RestartPolicy       manager.RestartPolicy   `json:"restart_policy"`
+	Stop                manager.StopSpec        `json:"stop"`
+	Env                 map[string]string       `json:"env"`
+	EnvFiles            []string                `json:"env_files"`
+	CleanEnv            bool                    `json:"clean_env"`
+	HealthCheck         manager.HealthCheck     `json:"health_check"`
+	DependsOn           []manager.Dependency    `json:"depends_on"`
+	Autostart           bool                    `json:"autostart"`
+	LogRotation         logstore.RotationPolicy `json:"log_rotation"`
+	LongLines           manager.LongLines       `json:"long_lines"`
+	Multiline           manager.Multiline       `json:"multiline"`
+	Resources           manager.ResourceLimits  `json:"resources"`
+	RunAsUser           string                  `json:"run_as_user"`
+	RunAsGroup          string                  `json:"run_as_group"`
+	SupplementaryGroups []string                `json:"supplementary_groups"`
+	// Octal file mode creation mask, such as "027"
+	Umask   string          `json:"umask"`
+	Sandbox manager.Sandbox `json:"sandbox"`
+	// Limits keyed by resource name, such as "nofile", "core" or "nproc"
+	Rlimits    map[string]manager.Rlimit `json:"rlimits"`
+	Scheduling manager.Scheduling        `json:"scheduling"`
+	// Run in a pseudo-terminal, with the window size of tty_size
+	TTY     bool            `json:"tty"`
+	TTYSize manager.TTYSize `json:"tty_size"`
 }
 
 type ServiceIDRequest struct {
 	ServiceID string `json:"service_id"`
 }
+
+type StopServiceRequest struct {
+	ServiceID string `json:"service_id"`
+	// TimeoutSeconds overrides the stop timeout of the service for this call
+	TimeoutSeconds *float64 `json:"timeout_seconds" binding:"omitempty,gte=0"`
+}
+
+type ResizeTTYRequest struct {
+	ServiceID string `json:"service_id"`
+	Rows      uint16 `json:"rows" binding:"required"`
+	Cols      uint16 `json:"cols" binding:"required"`
+}
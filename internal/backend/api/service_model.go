@@ -1,13 +1,37 @@
 package api
 
-import "service-manager/internal/manager"
+import (
+	"service-manager/internal/manager"
+	"time"
+)
 
 type ServiceData struct {
-	ID               string          `json:"id"`
-	Name             string          `json:"name"`
-	Cmd              manager.Command `json:"cmd"`
-	ExecuteDirectory string          `json:"execute_directory"`
-	IsRunning        bool            `json:"is_running"`
+	ID                  string                    `json:"id"`
+	Name                string                    `json:"name"`
+	Cmd                 manager.Command           `json:"cmd"`
+	ExecuteDirectory    string                    `json:"execute_directory"`
+	IsRunning           bool                      `json:"is_running"`
+	Status              manager.ServiceStatus     `json:"status"`
+	RestartPolicy       manager.RestartPolicy     `json:"restart_policy"`
+	Stop                manager.StopSpec          `json:"stop"`
+	RestartAttempts     int                       `json:"restart_attempts"`
+	NextRetryAt         *time.Time                `json:"next_retry_at,omitempty"`
+	HealthCheck         manager.HealthCheck       `json:"health_check"`
+	Health              manager.HealthState       `json:"health"`
+	DependsOn           []manager.Dependency      `json:"depends_on"`
+	Autostart           bool                      `json:"autostart"`
+	LongLines           manager.LongLines         `json:"long_lines"`
+	Multiline           manager.Multiline         `json:"multiline"`
+	Resources           manager.ResourceLimits    `json:"resources"`
+	RunAsUser           string                    `json:"run_as_user,omitempty"`
+	RunAsGroup          string                    `json:"run_as_group,omitempty"`
+	SupplementaryGroups []string                  `json:"supplementary_groups,omitempty"`
+	Umask               string                    `json:"umask,omitempty"`
+	Sandbox             manager.Sandbox           `json:"sandbox"`
+	Rlimits             map[string]manager.Rlimit `json:"rlimits,omitempty"`
+	Scheduling          manager.Scheduling        `json:"scheduling"`
+	TTY                 bool                      `json:"tty"`
+	TTYSize             manager.TTYSize           `json:"tty_size"`
 }
 
 type ServiceMetrics struct {
@@ -1,14 +1,114 @@
 package api
 
+import (
+	"service-manager/internal/logstore"
+	"time"
+)
+
 type StreamEvent string
 
 const (
 	EVENT_INITIAL StreamEvent = "event_initial"
 	EVENT_APPEND  StreamEvent = "event_append"
 	EVENT_ERROR   StreamEvent = "event_error"
+	EVENT_GAP     StreamEvent = "event_gap"
+	// A service changed status, sent over WebSocket connections
+	EVENT_STATUS StreamEvent = "event_status"
+	// A WebSocket request succeeded
+	EVENT_ACK StreamEvent = "event_ack"
 )
 
 type StreamMessage struct {
 	Type StreamEvent `json:"type"`
 	Data any         `json:"data"`
+	// Subscription the message belongs to, only set over WebSocket connections
+	Subscription string `json:"subscription,omitempty"`
+	// Request the message answers, only set over WebSocket connections
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// StreamQuery selects the lines sent by a stream. Tail and Since only apply
+// to the lines sent before the live ones, the filters to every line.
+type StreamQuery struct {
+	// Number of last lines sent initially, 10000 by default
+	Tail *int `form:"tail" json:"tail" binding:"omitempty,gte=0,lte=100000"`
+	// Only lines captured at or after this RFC 3339 time are sent initially
+	Since *time.Time `form:"since" json:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	// Only lines matching this regular expression are sent
+	Include string `form:"include" json:"include"`
+	// Lines matching this regular expression are not sent
+	Exclude string `form:"exclude" json:"exclude"`
+	// Only lines with a detected level at least as severe are sent
+	MinLevel string `form:"min_level" json:"min_level" binding:"omitempty,oneof=trace debug info warn error fatal"`
+	LineFormat
+}
+
+// LineFormat is how lines are rendered for a client. Lines are stored as
+// captured and rendered when they are read.
+type LineFormat struct {
+	// ANSI escape sequences are kept (default), stripped, or turned into HTML
+	// with coloured spans, which escapes the rest of the line
+	ANSI string `form:"ansi" json:"ansi" binding:"omitempty,oneof=keep strip html"`
+	// Carriage returns are kept (default), or only the text written last over
+	// the line is sent, the way a terminal shows a progress bar
+	CR string `form:"cr" json:"cr" binding:"omitempty,oneof=keep last"`
+}
+
+// Render returns a line in the format.
+func (f LineFormat) Render(line string) string {
+	if f.CR == "last" {
+		line = logstore.CollapseCarriageReturns(line)
+	}
+
+	switch f.ANSI {
+	case "strip":
+		return logstore.StripANSI(line)
+	case "html":
+		return logstore.ANSIToHTML(line)
+	}
+
+	return line
+}
+
+// StreamGap is the range of lines a client missed because it could not keep up
+// with the stream.
+type StreamGap struct {
+	FromSeq uint64 `json:"from_seq"`
+	ToSeq   uint64 `json:"to_seq"`
+}
+
+// LogLine is a captured line of a service log. Lines logged before sequence
+// numbers were recorded have no seq and time.
+type LogLine struct {
+	Seq    uint64     `json:"seq,omitempty"`
+	Time   *time.Time `json:"time,omitempty"`
+	Stream string     `json:"stream"`
+	Line   string     `json:"line"`
+}
+
+func NewLogLine(entry logstore.Entry, format LineFormat) LogLine {
+	logLine := LogLine{
+		Seq:    entry.Seq,
+		Stream: entry.Stream,
+		Line:   format.Render(entry.Line),
+	}
+
+	if !entry.Time.IsZero() {
+		logLine.Time = &entry.Time
+	}
+
+	return logLine
+}
+
+func NewLogLines(entries []logstore.Entry, format LineFormat) []LogLine {
+	if entries == nil {
+		return nil
+	}
+
+	logLines := make([]LogLine, 0, len(entries))
+	for _, entry := range entries {
+		logLines = append(logLines, NewLogLine(entry, format))
+	}
+
+	return logLines
 }
@@ -0,0 +1,38 @@
+package api
+
+type WebSocketAction string
+
+const (
+	// Start sending the logs of a service under a subscription id
+	WS_SUBSCRIBE WebSocketAction = "subscribe"
+	// Stop sending the logs of a subscription
+	WS_UNSUBSCRIBE WebSocketAction = "unsubscribe"
+	// Hold back the logs of a subscription until it is resumed
+	WS_PAUSE WebSocketAction = "pause"
+	// Send the logs of a paused subscription again
+	WS_RESUME WebSocketAction = "resume"
+	// Start a service
+	WS_START WebSocketAction = "start"
+	// Stop a service
+	WS_STOP WebSocketAction = "stop"
+)
+
+// WebSocketRequest is a message sent by a client over a WebSocket connection.
+// Every request is answered with an event_ack or event_error message carrying
+// its request id.
+type WebSocketRequest struct {
+	// Chosen by the client to match the answer to the request
+	RequestID string          `json:"request_id"`
+	Action    WebSocketAction `json:"action" binding:"required,oneof=subscribe unsubscribe pause resume start stop"`
+	// Chosen by the client when subscribing, log messages carry it
+	SubscriptionID string `json:"subscription_id"`
+	// Service of a subscribe, start or stop action
+	ServiceID string `json:"service_id"`
+	// Streams of a subscription, combined by default
+	Stream string `json:"stream" binding:"omitempty,oneof=stdout stderr combined"`
+	// Sequence number of the last line received, the subscription resumes after it
+	LastSeq uint64 `json:"last_seq"`
+	// Overrides the stop timeout of the service for a stop action
+	TimeoutSeconds *float64 `json:"timeout_seconds" binding:"omitempty,gte=0"`
+	StreamQuery
+}
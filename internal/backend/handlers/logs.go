@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"service-manager/internal/backend/api"
+	"service-manager/internal/backend/helpers"
+	"service-manager/internal/logstore"
+	"service-manager/internal/manager"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const DEFAULT_SEARCH_LIMIT = 100
+
+type LogsHandler struct {
+	ServiceManager *manager.ServiceManager
+	LogsDir        string
+}
+
+func NewLogsHandler(sm *manager.ServiceManager, logsDir string) *LogsHandler {
+	return &LogsHandler{
+		ServiceManager: sm,
+		LogsDir:        logsDir,
+	}
+}
+
+// SearchLogs godoc
+// @Summary      Search the logs of a service
+// @Description  Returns a page of the log lines of a service matching the filters, oldest first, searching through rotated and compressed segments. Without a cursor the newest matching lines are returned. Lines logged before sequence numbers were recorded are not searched.
+// @Tags         logs
+// @Produce      json
+// @Param        serviceID       path      string  true   "Service ID"
+// @Param        stream          query     string  false  "Stream to search, both by default"  Enums(stdout, stderr, both)
+// @Param        from            query     string  false  "Only lines captured at or after this RFC 3339 time"
+// @Param        to              query     string  false  "Only lines captured before this RFC 3339 time"
+// @Param        q               query     string  false  "Text the lines must contain, a regular expression if regex is set"
+// @Param        regex           query     bool    false  "Match q as a regular expression"
+// @Param        case_sensitive  query     bool    false  "Match q case sensitively"
+// @Param        after           query     int     false  "Cursor, returns the oldest matching lines after this sequence number"
+// @Param        before          query     int     false  "Cursor, returns the newest matching lines before this sequence number"
+// @Param        limit           query     int     false  "Maximum number of lines, 100 by default and at most 1000"
+// @Param        ansi            query     string  false  "ANSI escape sequences are kept, stripped or turned into HTML, keep by default"  Enums(keep, strip, html)
+// @Param        cr              query     string  false  "Carriage returns are kept, or only the text written last over a line is returned, keep by default"  Enums(keep, last)
+// @Success      200             {object}  api.SearchLogsResponse
+// @Failure      400             {object}  api.ErrorResponse
+// @Failure      422             {object}  api.ErrorResponse
+// @Failure      500             {object}  api.ErrorResponse
+// @Router       /logs/{serviceID} [get]
+func (h *LogsHandler) SearchLogs(c *gin.Context) {
+	req, ok := helpers.BindQueryOrAbort[api.SearchLogsRequest](c)
+	if !ok {
+		return
+	}
+
+	serviceID := c.Param("serviceID")
+
+	if !h.ServiceManager.ServiceExists(serviceID) {
+		apiError := api.NewError(
+			"error fetching service",
+			fmt.Sprintf("Could not find service with id '%s'", serviceID),
+		)
+		c.JSON(
+			http.StatusInternalServerError,
+			apiError,
+		)
+		return
+	}
+
+	match, err := lineMatcher(req.Query, req.Regex, req.CaseSensitive)
+	if err != nil {
+		apiError := api.NewError(
+			"Invalid regular expression",
+			err.Error(),
+		)
+		c.JSON(
+			http.StatusBadRequest,
+			apiError,
+		)
+		return
+	}
+
+	streamNames := []string{"stdout", "stderr"}
+	if req.Stream == "stdout" || req.Stream == "stderr" {
+		streamNames = []string{req.Stream}
+	}
+
+	paths := make([]string, 0, len(streamNames))
+	for _, streamName := range streamNames {
+		paths = append(paths, filepath.Join(h.LogsDir, serviceID, streamName))
+	}
+
+	query := logstore.Query{
+		Match:   match,
+		Forward: req.After != nil,
+		Limit:   DEFAULT_SEARCH_LIMIT,
+	}
+	if req.From != nil {
+		query.From = *req.From
+	}
+	if req.To != nil {
+		query.To = *req.To
+	}
+	if req.After != nil {
+		query.After = *req.After
+	}
+	if req.Before != nil {
+		query.Before = *req.Before
+	}
+	if req.Limit != 0 {
+		query.Limit = req.Limit
+	}
+
+	page, err := logstore.Search(paths, query)
+	if err != nil {
+		apiError := api.NewError(
+			"error searching logs",
+			err.Error(),
+		)
+		c.JSON(
+			http.StatusInternalServerError,
+			apiError,
+		)
+		return
+	}
+
+	response := api.SearchLogsResponse{
+		Lines: api.NewLogLines(page.Entries, req.LineFormat),
+	}
+
+	if len(page.Entries) > 0 {
+		first := page.Entries[0].Seq
+		last := page.Entries[len(page.Entries)-1].Seq
+
+		// Lines exist in the direction the client came from, and in the
+		// direction of the search if the page was full
+		if query.Forward {
+			if query.After != 0 {
+				response.PrevCursor = &first
+			}
+			if page.More {
+				response.NextCursor = &last
+			}
+		} else {
+			if page.More {
+				response.PrevCursor = &first
+			}
+			if query.Before != 0 {
+				response.NextCursor = &last
+			}
+		}
+	}
+
+	if response.Lines == nil {
+		response.Lines = []api.LogLine{}
+	}
+
+	c.JSON(
+		http.StatusOK,
+		response,
+	)
+}
+
+// lineMatcher returns the function matching lines against the query, nil if
+// every line matches. Lines are matched without their ANSI escape sequences.
+func lineMatcher(query string, regex bool, caseSensitive bool) (func(line string) bool, error) {
+	if query == "" {
+		return nil, nil
+	}
+
+	var match func(text string) bool
+
+	if regex {
+		pattern, err := regexp.Compile(query)
+		if err != nil {
+			return nil, err
+		}
+
+		if !caseSensitive {
+			pattern = regexp.MustCompile("(?i)" + query)
+		}
+
+		match = pattern.MatchString
+	} else if caseSensitive {
+		match = func(text string) bool {
+			return strings.Contains(text, query)
+		}
+	} else {
+		loweredQuery := strings.ToLower(query)
+
+		match = func(text string) bool {
+			return strings.Contains(strings.ToLower(text), loweredQuery)
+		}
+	}
+
+	return func(line string) bool {
+		return match(logstore.StripANSI(line))
+	}, nil
+}
@@ -6,6 +6,7 @@ import (
 	"service-manager/internal/backend/api"
 	"service-manager/internal/backend/helpers"
 	"service-manager/internal/manager"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -42,6 +43,29 @@ func (h *ServiceManagerHandler) RegisterService(c *gin.Context) {
 		req.CommandName,
 		req.CommandArgs,
 		req.ExecuteDirectory,
+		manager.ServiceConfig{
+			RestartPolicy:       req.RestartPolicy,
+			Stop:                req.Stop,
+			Env:                 req.Env,
+			EnvFiles:            req.EnvFiles,
+			CleanEnv:            req.CleanEnv,
+			HealthCheck:         req.HealthCheck,
+			DependsOn:           req.DependsOn,
+			Autostart:           req.Autostart,
+			LogRotation:         req.LogRotation,
+			LongLines:           req.LongLines,
+			Multiline:           req.Multiline,
+			Resources:           req.Resources,
+			RunAsUser:           req.RunAsUser,
+			RunAsGroup:          req.RunAsGroup,
+			SupplementaryGroups: req.SupplementaryGroups,
+			Umask:               req.Umask,
+			Sandbox:             req.Sandbox,
+			Rlimits:             req.Rlimits,
+			Scheduling:          req.Scheduling,
+			TTY:                 req.TTY,
+			TTYSize:             req.TTYSize,
+		},
 	)
 	if err != nil {
 		apiError := api.NewError(
@@ -98,7 +122,7 @@ func (h *ServiceManagerHandler) RemoveService(c *gin.Context) {
 
 // StartService godoc
 // @Summary      Start a service
-// @Description  Starts a registered service.
+// @Description  Starts a registered service after the services it depends on.
 // @Tags         manager
 // @Accept       json
 // @Produce      json
@@ -135,22 +159,28 @@ func (h *ServiceManagerHandler) StartService(c *gin.Context) {
 
 // StopService godoc
 // @Summary      Stop a service
-// @Description  Stops a running service.
+// @Description  Stops a running service after the services depending on it. The stop signal is sent first and the process group is killed once the timeout expires.
 // @Tags         manager
 // @Accept       json
 // @Produce      json
-// @Param        service  body      api.ServiceIDRequest  true  "Service ID"
-// @Success      200      {object}  map[string]string
+// @Param        service  body      api.StopServiceRequest  true  "Service ID and optional timeout override"
+// @Success      200      {object}  map[string]interface{}
 // @Failure      400      {object}  api.ErrorResponse
 // @Failure      500      {object}  api.ErrorResponse
 // @Router       /manager/stop [post]
 func (h *ServiceManagerHandler) StopService(c *gin.Context) {
-	req, ok := helpers.BindOrAbort[api.ServiceIDRequest](c)
+	req, ok := helpers.BindOrAbort[api.StopServiceRequest](c)
 	if !ok {
 		return
 	}
 
-	err := h.ServiceManager.StopService(req.ServiceID)
+	var gracePeriod *time.Duration
+	if req.TimeoutSeconds != nil {
+		timeout := time.Duration(*req.TimeoutSeconds * float64(time.Second))
+		gracePeriod = &timeout
+	}
+
+	graceful, err := h.ServiceManager.StopService(req.ServiceID, gracePeriod)
 	if err != nil {
 		apiError := api.NewError(
 			"Failed to stop service",
@@ -166,7 +196,7 @@ func (h *ServiceManagerHandler) StopService(c *gin.Context) {
 
 	c.JSON(
 		http.StatusOK,
-		gin.H{"message": "service stopped"},
+		gin.H{"message": "service stopped", "graceful": graceful},
 	)
 }
 
@@ -183,14 +213,38 @@ func (h *ServiceManagerHandler) GetServices(c *gin.Context) {
 	response := make([]api.ServiceData, 0, len(services))
 
 	for _, service := range services {
-		IsRunning := service.GetStatus() == manager.SERVICE_RUNNING
+		status := service.GetStatus()
+		restartState := service.GetRestartState()
 
 		serviceData := api.ServiceData{
-			ID:               service.ID,
-			Name:             service.Name,
-			Cmd:              service.Cmd,
-			ExecuteDirectory: service.ExecuteDirectory,
-			IsRunning:        IsRunning,
+			ID:                  service.ID,
+			Name:                service.Name,
+			Cmd:                 service.Cmd,
+			ExecuteDirectory:    service.ExecuteDirectory,
+			IsRunning:           status.IsActive(),
+			Status:              status,
+			RestartPolicy:       service.Config.RestartPolicy,
+			Stop:                service.Config.Stop,
+			RestartAttempts:     restartState.Attempts,
+			HealthCheck:         service.Config.HealthCheck,
+			Health:              service.GetHealth(),
+			DependsOn:           service.Config.DependsOn,
+			Autostart:           service.Config.Autostart,
+			LongLines:           service.Config.LongLines,
+			Multiline:           service.Config.Multiline,
+			Resources:           service.Config.Resources,
+			RunAsUser:           service.Config.RunAsUser,
+			RunAsGroup:          service.Config.RunAsGroup,
+			SupplementaryGroups: service.Config.SupplementaryGroups,
+			Umask:               service.Config.Umask,
+			Sandbox:             service.Config.Sandbox,
+			Rlimits:             service.Config.Rlimits,
+			Scheduling:          service.Config.Scheduling,
+			TTY:                 service.Config.TTY,
+			TTYSize:             service.Config.TTYSize,
+		}
+		if !restartState.NextRetryAt.IsZero() {
+			serviceData.NextRetryAt = &restartState.NextRetryAt
 		}
 		response = append(response, serviceData)
 	}
@@ -281,3 +335,99 @@ func (h *ServiceManagerHandler) GetNetworkInfo(c *gin.Context) {
 		service.GetNetworkInfo(),
 	)
 }
+
+// ResizeTTY godoc
+// @Summary      Resize the terminal of a service
+// @Description  Sets the window size of the pseudo-terminal of a running service registered with tty, which gets SIGWINCH. The next runs start with the size the service was registered with.
+// @Tags         manager
+// @Accept       json
+// @Produce      json
+// @Param        service  body      api.ResizeTTYRequest  true  "Service ID and window size"
+// @Success      200      {object}  map[string]interface{}
+// @Failure      400      {object}  api.ErrorResponse
+// @Failure      422      {object}  api.ErrorResponse
+// @Failure      500      {object}  api.ErrorResponse
+// @Router       /manager/tty/resize [post]
+func (h *ServiceManagerHandler) ResizeTTY(c *gin.Context) {
+	req, ok := helpers.BindOrAbort[api.ResizeTTYRequest](c)
+	if !ok {
+		return
+	}
+
+	service, err := h.ServiceManager.GetService(req.ServiceID)
+	if err != nil {
+		apiError := api.NewError(
+			"error fetching service",
+			err.Error(),
+		)
+
+		c.JSON(
+			http.StatusInternalServerError,
+			apiError,
+		)
+		return
+	}
+
+	err = service.ResizeTTY(manager.TTYSize{Rows: req.Rows, Cols: req.Cols})
+	if err != nil {
+		apiError := api.NewError(
+			"Failed to resize terminal",
+			err.Error(),
+		)
+
+		c.JSON(
+			http.StatusInternalServerError,
+			apiError,
+		)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		gin.H{"message": "terminal resized"},
+	)
+}
+
+// GetServiceRuns godoc
+// @Summary      Get the run history of a service
+// @Description  Returns the recorded runs of a service, most recent first, with exit code, terminating signal, exit reason and the last stderr lines.
+// @Tags         manager
+// @Produce      json
+// @Param        serviceID  path      string  true  "Service ID"
+// @Success      200        {array}   manager.RunRecord
+// @Failure      500        {object}  api.ErrorResponse
+// @Router       /manager/services/{serviceID}/runs [get]
+func (h *ServiceManagerHandler) GetServiceRuns(c *gin.Context) {
+	service, err := h.ServiceManager.GetService(c.Param("serviceID"))
+	if err != nil {
+		apiError := api.NewError(
+			"error fetching service",
+			err.Error(),
+		)
+
+		c.JSON(
+			http.StatusInternalServerError,
+			apiError,
+		)
+		return
+	}
+
+	c.JSON(
+		http.StatusOK,
+		service.GetRuns(),
+	)
+}
+
+// GetStartupSummary godoc
+// @Summary      Get the autostart summary
+// @Description  Returns which autostart services came up when the manager booted and which failed. finished_at is zero while services are still being started.
+// @Tags         manager
+// @Produce      json
+// @Success      200  {object}  manager.StartupSummary
+// @Router       /manager/startup [get]
+func (h *ServiceManagerHandler) GetStartupSummary(c *gin.Context) {
+	c.JSON(
+		http.StatusOK,
+		h.ServiceManager.GetStartupSummary(),
+	)
+}
@@ -1,20 +1,31 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"path/filepath"
+	"regexp"
 	"service-manager/internal/backend/api"
 	"service-manager/internal/backend/utils"
+	"service-manager/internal/logstore"
 	"service-manager/internal/manager"
+	"slices"
+	"strconv"
+	"time"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/gin-gonic/gin"
 )
 
-const INITIAL_LINES_OF_LOG = 10000
+const (
+	INITIAL_LINES_OF_LOG = 10000
+	// Number of lines read from the log hub at once
+	HUB_READ_BATCH = 1000
+	// Number of lines read from each log file at once when resending the lines
+	// a client missed
+	MISSED_LINES_PAGE = 1000
+)
 
 type StreamHandler struct {
 	ServiceManager *manager.ServiceManager
@@ -29,6 +40,12 @@ func NewStreamHandler(sm *manager.ServiceManager, logsDir string) *StreamHandler
 }
 
 func sendSSE(c *gin.Context, msg any) bool {
+	return sendSSEWithID(c, 0, msg)
+}
+
+// sendSSEWithID sends a message with an event id, which the client sends back
+// as Last-Event-ID when it reconnects. An id of 0 is not sent.
+func sendSSEWithID(c *gin.Context, id uint64, msg any) bool {
 	jsonBytes, err := json.Marshal(msg)
 	if err != nil {
 		// This error is a server-side issue, so we can't easily send it to the client.
@@ -37,7 +54,11 @@ func sendSSE(c *gin.Context, msg any) bool {
 		return false
 	}
 
-	_, err = fmt.Fprintf(c.Writer, "data: %s\n\n", jsonBytes)
+	if id != 0 {
+		_, err = fmt.Fprintf(c.Writer, "id: %d\ndata: %s\n\n", id, jsonBytes)
+	} else {
+		_, err = fmt.Fprintf(c.Writer, "data: %s\n\n", jsonBytes)
+	}
 	if err != nil {
 		// This likely means the client has closed the connection.
 		log.Printf("Error writing to SSE stream: %v", err)
@@ -62,10 +83,87 @@ func sendSSEError(c *gin.Context, title, detail string) bool {
 // @Description  Streams the standard output log of a service using Server-Sent Events (SSE).
 // @Tags         stream
 // @Produce      text/event-stream
-// @Param        serviceID  path      string  true  "Service ID"
-// @Success      200        {object}  api.StreamMessage  "SSE stream of log data"
+// @Param        serviceID      path      string  true   "Service ID"
+// @Param        Last-Event-ID  header    int     false  "Sequence number of the last line received, the stream resumes after it"
+// @Param        last_event_id  query     int     false  "Same as the Last-Event-ID header, for clients that cannot set it"
+// @Param        tail           query     int     false  "Number of last lines sent initially, 10000 by default"
+// @Param        since          query     string  false  "Only send initial lines captured at or after this RFC 3339 time"
+// @Param        include        query     string  false  "Only send lines matching this regular expression"
+// @Param        exclude        query     string  false  "Do not send lines matching this regular expression"
+// @Param        min_level      query     string  false  "Only send lines with a detected level at least as severe"  Enums(trace, debug, info, warn, error, fatal)
+// @Param        ansi           query     string  false  "ANSI escape sequences are kept, stripped or turned into HTML, keep by default"  Enums(keep, strip, html)
+// @Param        cr             query     string  false  "Carriage returns are kept, or only the text written last over a line is sent, keep by default"  Enums(keep, last)
+// @Success      200            {object}  api.StreamMessage  "SSE stream of log data, lines are sent as api.LogLine"
 // @Router       /stream/stdout/{serviceID} [get]
 func (h *StreamHandler) StreamStdout(c *gin.Context) {
+	h.streamLogs(c, "stdout")
+}
+
+// StreamStderr godoc
+// @Summary      Stream service stderr logs
+// @Description  Streams the standard error log of a service using Server-Sent Events (SSE).
+// @Tags         stream
+// @Produce      text/event-stream
+// @Param        serviceID      path      string  true   "Service ID"
+// @Param        Last-Event-ID  header    int     false  "Sequence number of the last line received, the stream resumes after it"
+// @Param        last_event_id  query     int     false  "Same as the Last-Event-ID header, for clients that cannot set it"
+// @Param        tail           query     int     false  "Number of last lines sent initially, 10000 by default"
+// @Param        since          query     string  false  "Only send initial lines captured at or after this RFC 3339 time"
+// @Param        include        query     string  false  "Only send lines matching this regular expression"
+// @Param        exclude        query     string  false  "Do not send lines matching this regular expression"
+// @Param        min_level      query     string  false  "Only send lines with a detected level at least as severe"  Enums(trace, debug, info, warn, error, fatal)
+// @Param        ansi           query     string  false  "ANSI escape sequences are kept, stripped or turned into HTML, keep by default"  Enums(keep, strip, html)
+// @Param        cr             query     string  false  "Carriage returns are kept, or only the text written last over a line is sent, keep by default"  Enums(keep, last)
+// @Success      200            {object}  api.StreamMessage  "SSE stream of log data, lines are sent as api.LogLine"
+// @Router       /stream/stderr/{serviceID} [get]
+func (h *StreamHandler) StreamStderr(c *gin.Context) {
+	h.streamLogs(c, "stderr")
+}
+
+// StreamCombined godoc
+// @Summary      Stream service stdout and stderr logs
+// @Description  Streams the standard output and standard error logs of a service interleaved in capture order using Server-Sent Events (SSE). Each line carries the stream it was captured from.
+// @Tags         stream
+// @Produce      text/event-stream
+// @Param        serviceID      path      string  true   "Service ID"
+// @Param        Last-Event-ID  header    int     false  "Sequence number of the last line received, the stream resumes after it"
+// @Param        last_event_id  query     int     false  "Same as the Last-Event-ID header, for clients that cannot set it"
+// @Param        tail           query     int     false  "Number of last lines sent initially, 10000 by default"
+// @Param        since          query     string  false  "Only send initial lines captured at or after this RFC 3339 time"
+// @Param        include        query     string  false  "Only send lines matching this regular expression"
+// @Param        exclude        query     string  false  "Do not send lines matching this regular expression"
+// @Param        min_level      query     string  false  "Only send lines with a detected level at least as severe"  Enums(trace, debug, info, warn, error, fatal)
+// @Param        ansi           query     string  false  "ANSI escape sequences are kept, stripped or turned into HTML, keep by default"  Enums(keep, strip, html)
+// @Param        cr             query     string  false  "Carriage returns are kept, or only the text written last over a line is sent, keep by default"  Enums(keep, last)
+// @Success      200            {object}  api.StreamMessage  "SSE stream of log data, lines are sent as api.LogLine"
+// @Router       /stream/combined/{serviceID} [get]
+func (h *StreamHandler) StreamCombined(c *gin.Context) {
+	h.streamLogs(c, "stdout", "stderr")
+}
+
+// lastEventID returns the sequence number a client resumes after, 0 when it
+// is not resuming.
+func lastEventID(c *gin.Context) (uint64, error) {
+	value := c.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = c.Query("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("last event id '%s' is not a sequence number", value)
+	}
+
+	return id, nil
+}
+
+// streamLogs streams the logs of the given streams of a service over SSE,
+// every line with its sequence number as event id so a client can resume with
+// Last-Event-ID.
+func (h *StreamHandler) streamLogs(c *gin.Context, streamNames ...string) {
 	// Set headers for SSE
 	c.Writer.Header().Set("Content-Type", "text/event-stream")
 	c.Writer.Header().Set("Cache-Control", "no-cache")
@@ -74,179 +172,334 @@ func (h *StreamHandler) StreamStdout(c *gin.Context) {
 
 	serviceID := c.Param("serviceID")
 
-	if !h.ServiceManager.ServiceExists(serviceID) {
-		sendSSEError(c, "Service does not exist", fmt.Sprintf("Could not find service with id '%s'", serviceID))
+	resumeAfter, err := lastEventID(c)
+	if err != nil {
+		sendSSEError(c, "Invalid Last-Event-ID", err.Error())
 		return
 	}
 
-	fullFilePath := filepath.Join(h.LogsDir, serviceID, "stdout")
-
-	// Read initial lines, but handle "file not found" gracefully.
-	lines, err := utils.ReadLines(fullFilePath, INITIAL_LINES_OF_LOG)
-	if err != nil && !os.IsNotExist(err) {
-		sendSSEError(c, "Error reading initial lines", err.Error())
+	var query api.StreamQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		sendSSEError(c, "Invalid query parameters", err.Error())
 		return
 	}
 
-	initialMessage := api.StreamMessage{
-		Type: api.EVENT_INITIAL,
-		Data: lines, // lines will be nil if the file didn't exist, which is fine.
-	}
-	if !sendSSE(c, initialMessage) {
-		return // Client disconnected
+	h.followLogs(c.Request.Context(), serviceID, streamNames, query, resumeAfter, func(id uint64, msg api.StreamMessage) bool {
+		return sendSSEWithID(c, id, msg)
+	})
+}
+
+// followLogs sends the logs of the given streams of a service, merged in
+// capture order, until the context is done or send fails. The last lines are
+// sent initially, then live ones. Every line is sent with its sequence number
+// as id. A client resuming after a sequence number receives the lines after
+// it as appends instead. Errors are sent as error messages.
+//
+// Lines older than the oldest one buffered in the log hub are read from the
+// log files, the hub provides the rest and the live lines.
+func (h *StreamHandler) followLogs(ctx context.Context, serviceID string, streamNames []string, query api.StreamQuery, resumeAfter uint64, send func(id uint64, msg api.StreamMessage) bool) {
+	sendError := func(title, detail string) {
+		send(0, api.StreamMessage{
+			Type: api.EVENT_ERROR,
+			Data: api.NewError(title, detail),
+		})
 	}
 
-	// Stream message on file modification
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		sendSSEError(c, "Error creating file watcher", err.Error())
+	if !h.ServiceManager.ServiceExists(serviceID) {
+		sendError("Service does not exist", fmt.Sprintf("Could not find service with id '%s'", serviceID))
 		return
 	}
-	defer watcher.Close()
 
-	// It's crucial to add the directory to the watcher, not the file.
-	// This allows us to detect when the file is created.
-	logDir := filepath.Dir(fullFilePath)
-	err = watcher.Add(logDir)
+	filter, err := newStreamFilter(streamNames, query)
 	if err != nil {
-		sendSSEError(c, "Error adding file path to watcher", err.Error())
+		sendError("Invalid filter", err.Error())
 		return
 	}
 
-	for {
-		select {
-		case <-c.Request.Context().Done():
-			log.Println("Client disconnected")
+	tail := INITIAL_LINES_OF_LOG
+	if query.Tail != nil {
+		tail = *query.Tail
+	}
+
+	var since time.Time
+	if query.Since != nil {
+		since = *query.Since
+	}
+
+	hub := h.ServiceManager.LogHub()
+
+	// Sequence number of the last line read, the hub is read after it
+	var cursor uint64
+
+	if resumeAfter == 0 {
+		var lines []logstore.Entry
+		lines, cursor, err = h.readBacklog(serviceID, filter, tail, since)
+		if err != nil {
+			sendError("Error reading initial lines", err.Error())
 			return
-		case event := <-watcher.Events:
-			// We only care about events for our specific file.
-			if event.Name != fullFilePath {
-				continue
-			}
+		}
 
-			// We care about the file being written to or created.
-			if !(event.Has(fsnotify.Write) || event.Has(fsnotify.Create)) {
-				continue
+		var initialID uint64
+		if len(lines) > 0 {
+			initialID = lines[len(lines)-1].Seq
+		}
+
+		initialMessage := api.StreamMessage{
+			Type: api.EVENT_INITIAL,
+			Data: api.NewLogLines(lines, query.LineFormat), // lines will be empty if nothing was logged, which is fine.
+		}
+		if !send(initialID, initialMessage) {
+			return // Client disconnected
+		}
+	} else {
+		// Resend what the client missed that the hub no longer buffers, across
+		// rotated segments. The hub provides the rest, and reports a gap for
+		// lines missing from both.
+		var sent bool
+		cursor, sent, err = h.sendMissed(serviceID, filter, resumeAfter, func(line logstore.Entry) bool {
+			return send(line.Seq, newAppendMessage(line, query.LineFormat))
+		})
+		if err != nil {
+			sendError("Error reading missed lines", err.Error())
+			return
+		}
+		if !sent {
+			return // Client disconnected
+		}
+	}
+
+	for {
+		entries, gap, notify := hub.Read(serviceID, cursor, HUB_READ_BATCH)
+
+		if gap != nil {
+			// The client was too slow, the lines it missed were evicted
+			gapMessage := api.StreamMessage{
+				Type: api.EVENT_GAP,
+				Data: api.StreamGap{
+					FromSeq: gap.From,
+					ToSeq:   gap.To,
+				},
+			}
+			if !send(0, gapMessage) {
+				return // Client disconnected
 			}
+		}
 
-			lastLine, err := utils.ReadLastLine(fullFilePath)
-			if err != nil {
-				// Don't send an error here, as it could be a transient read issue.
-				// We can just log it and wait for the next event.
-				log.Printf("Error reading last line: %v", err)
+		for _, entry := range entries {
+			cursor = entry.Seq
+			if !filter.keep(entry) {
 				continue
 			}
 
-			appendMessage := api.StreamMessage{
-				Type: api.EVENT_APPEND,
-				Data: lastLine,
-			}
-			if !sendSSE(c, appendMessage) {
+			if !send(entry.Seq, newAppendMessage(entry, query.LineFormat)) {
 				return // Client disconnected
 			}
-		case err := <-watcher.Errors:
-			log.Printf("Watcher error: %v", err)
-			// Optionally, send this error to the client.
-			sendSSEError(c, "File watcher error", err.Error())
-			return // Client disconnected
+		}
 
+		if len(entries) == HUB_READ_BATCH {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Client disconnected")
+			return
+		case <-notify:
+			if !h.ServiceManager.ServiceExists(serviceID) {
+				sendError("Service does not exist", fmt.Sprintf("Service with id '%s' was removed", serviceID))
+				return
+			}
 		}
 	}
 }
 
-// StreamStderr godoc
-// @Summary      Stream service stderr logs
-// @Description  Streams the standard error log of a service using Server-Sent Events (SSE).
-// @Tags         stream
-// @Produce      text/event-stream
-// @Param        serviceID  path      string  true  "Service ID"
-// @Success      200        {object}  api.StreamMessage  "SSE stream of log data"
-// @Router       /stream/stderr/{serviceID} [get]
-func (h *StreamHandler) StreamStderr(c *gin.Context) {
-	// Set headers for SSE
-	c.Writer.Header().Set("Content-Type", "text/event-stream")
-	c.Writer.Header().Set("Cache-Control", "no-cache")
-	c.Writer.Header().Set("Connection", "keep-alive")
-	c.Writer.Flush()
+// streamFilter selects the lines a stream sends.
+type streamFilter struct {
+	streamNames []string
+	include     *regexp.Regexp
+	exclude     *regexp.Regexp
+	minLevel    logstore.Level
+}
 
-	serviceID := c.Param("serviceID")
+func newStreamFilter(streamNames []string, query api.StreamQuery) (*streamFilter, error) {
+	filter := &streamFilter{
+		streamNames: streamNames,
+	}
 
-	if !h.ServiceManager.ServiceExists(serviceID) {
-		sendSSEError(c, "Service does not exist", fmt.Sprintf("Could not find service with id '%s'", serviceID))
-		return
+	var err error
+	if query.Include != "" {
+		filter.include, err = regexp.Compile(query.Include)
+		if err != nil {
+			return nil, fmt.Errorf("include: %w", err)
+		}
 	}
 
-	fullFilePath := filepath.Join(h.LogsDir, serviceID, "stderr")
+	if query.Exclude != "" {
+		filter.exclude, err = regexp.Compile(query.Exclude)
+		if err != nil {
+			return nil, fmt.Errorf("exclude: %w", err)
+		}
+	}
 
-	// Read initial lines, but handle "file not found" gracefully.
-	lines, err := utils.ReadLines(fullFilePath, INITIAL_LINES_OF_LOG)
-	if err != nil && !os.IsNotExist(err) {
-		sendSSEError(c, "Error reading initial lines", err.Error())
-		return
+	if query.MinLevel != "" {
+		filter.minLevel, _ = logstore.ParseLevel(query.MinLevel)
 	}
 
-	initialMessage := api.StreamMessage{
-		Type: api.EVENT_INITIAL,
-		Data: lines, // lines will be nil if the file didn't exist, which is fine.
+	return filter, nil
+}
+
+// keep reports whether a line is sent. The regular expressions match the text
+// of the line without ANSI escape sequences. Lines without a detected level are
+// not sent when a minimum level is set.
+func (f *streamFilter) keep(entry logstore.Entry) bool {
+	if !slices.Contains(f.streamNames, entry.Stream) {
+		return false
+	}
+
+	text := logstore.StripANSI(entry.Line)
+	if f.include != nil && !f.include.MatchString(text) {
+		return false
 	}
-	if !sendSSE(c, initialMessage) {
-		return // Client disconnected
+	if f.exclude != nil && f.exclude.MatchString(text) {
+		return false
 	}
+	if f.minLevel != logstore.LEVEL_UNKNOWN && logstore.DetectLevel(entry.Line) < f.minLevel {
+		return false
+	}
+	return true
+}
 
-	// Stream message on file modification
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		sendSSEError(c, "Error creating file watcher", err.Error())
-		return
+// readBacklog returns the last lines kept by the filter captured since the
+// given time, and the sequence number live lines follow. The newest lines come
+// from the hub and older ones from the log files.
+func (h *StreamHandler) readBacklog(serviceID string, filter *streamFilter, tail int, since time.Time) ([]logstore.Entry, uint64, error) {
+	// Live lines follow the last line logged even if none is sent initially,
+	// otherwise the hub reports the lines before them as missed. It is read
+	// before the hub, which has every line written since then.
+	var lastLogged uint64
+	for _, streamName := range []string{"stdout", "stderr"} {
+		last, err := logstore.LastSeq(filepath.Join(h.LogsDir, serviceID, streamName))
+		if err != nil {
+			return nil, 0, err
+		}
+		lastLogged = max(lastLogged, last)
 	}
-	defer watcher.Close()
 
-	// It's crucial to add the directory to the watcher, not the file.
-	// This allows us to detect when the file is created.
-	logDir := filepath.Dir(fullFilePath)
-	err = watcher.Add(logDir)
-	if err != nil {
-		sendSSEError(c, "Error adding file path to watcher", err.Error())
-		return
+	hubLines, firstInHub, cursor := readHub(h.ServiceManager.LogHub(), serviceID, tail, func(entry logstore.Entry) bool {
+		return filter.keep(entry) && !entry.Time.Before(since)
+	})
+	cursor = max(cursor, lastLogged)
+
+	remaining := tail - len(hubLines)
+	if remaining == 0 || firstInHub == 1 {
+		return hubLines, cursor, nil
 	}
 
+	var fileLines [][]logstore.Entry
+	for _, streamName := range filter.streamNames {
+		fullFilePath := filepath.Join(h.LogsDir, serviceID, streamName)
+		lines, err := utils.ReadLastLines(fullFilePath, remaining, firstInHub, since, filter.keep)
+		if err != nil {
+			return nil, 0, err
+		}
+		fileLines = append(fileLines, lines)
+	}
+
+	lines := logstore.MergeEntries(fileLines...)
+	if len(lines) > remaining {
+		lines = lines[len(lines)-remaining:]
+	}
+	if len(lines) > 0 {
+		cursor = max(cursor, lines[len(lines)-1].Seq)
+	}
+
+	return append(lines, hubLines...), cursor, nil
+}
+
+// sendMissed sends the lines kept by the filter captured after the sequence
+// number a client resumes after and older than the ones buffered in the hub,
+// reading the log files forward in pages. It returns the sequence number the
+// hub is read after, and false if sending failed.
+func (h *StreamHandler) sendMissed(serviceID string, filter *streamFilter, after uint64, send func(line logstore.Entry) bool) (uint64, bool, error) {
+	cursor := after
+
 	for {
-		select {
-		case <-c.Request.Context().Done():
-			log.Println("Client disconnected")
-			return
-		case event := <-watcher.Events:
-			// We only care about events for our specific file.
-			if event.Name != fullFilePath {
-				continue
+		// Read again for every page, as the hub evicts lines meanwhile
+		oldestInHub := h.ServiceManager.LogHub().Oldest(serviceID)
+		if oldestInHub != 0 && cursor+1 >= oldestInHub {
+			return cursor, true, nil
+		}
+
+		var fileLines [][]logstore.Entry
+		for _, streamName := range filter.streamNames {
+			fullFilePath := filepath.Join(h.LogsDir, serviceID, streamName)
+			lines, err := logstore.ReadAfter(fullFilePath, cursor, MISSED_LINES_PAGE)
+			if err != nil {
+				return 0, false, err
 			}
+			fileLines = append(fileLines, lines)
+		}
 
-			// We care about the file being written to or created.
-			if !(event.Has(fsnotify.Write) || event.Has(fsnotify.Create)) {
-				continue
+		// A stream whose page is full may have more lines after it, but
+		// none before the last of the first page of merged lines
+		lines := logstore.MergeEntries(fileLines...)
+		complete := len(lines) < MISSED_LINES_PAGE
+		if !complete {
+			lines = lines[:MISSED_LINES_PAGE]
+		}
+
+		for _, line := range lines {
+			if oldestInHub != 0 && line.Seq >= oldestInHub {
+				return cursor, true, nil
 			}
+			cursor = line.Seq
 
-			lastLine, err := utils.ReadLastLine(fullFilePath)
-			if err != nil {
-				// Don't send an error here, as it could be a transient read issue.
-				// We can just log it and wait for the next event.
-				log.Printf("Error reading last line: %v", err)
-				continue
+			if filter.keep(line) && !send(line) {
+				return cursor, false, nil
 			}
+		}
+
+		if complete {
+			return cursor, true, nil
+		}
+	}
+}
+
+// readHub reads the lines buffered in the hub. It returns the last limit lines
+// kept by keep, the sequence number of the first line read, which is 0 if none
+// was buffered, and that of the last one.
+func readHub(hub *logstore.Hub, serviceID string, limit int, keep func(entry logstore.Entry) bool) ([]logstore.Entry, uint64, uint64) {
+	var lines []logstore.Entry
+	var first, cursor uint64
+
+	for {
+		entries, _, _ := hub.Read(serviceID, cursor, HUB_READ_BATCH)
 
-			appendMessage := api.StreamMessage{
-				Type: api.EVENT_APPEND,
-				Data: lastLine,
+		for _, entry := range entries {
+			if first == 0 {
+				first = entry.Seq
 			}
-			if !sendSSE(c, appendMessage) {
-				return // Client disconnected
+			cursor = entry.Seq
+
+			if keep(entry) {
+				lines = append(lines, entry)
 			}
-		case err := <-watcher.Errors:
-			log.Printf("Watcher error: %v", err)
-			// Optionally, send this error to the client.
-			sendSSEError(c, "File watcher error", err.Error())
-			return // Client disconnected
+		}
 
+		if len(lines) > limit {
+			lines = lines[len(lines)-limit:]
 		}
+
+		if len(entries) < HUB_READ_BATCH {
+			return lines, first, cursor
+		}
+	}
+}
+
+// newAppendMessage wraps a line rendered in the format in an append message.
+func newAppendMessage(line logstore.Entry, format api.LineFormat) api.StreamMessage {
+	return api.StreamMessage{
+		Type: api.EVENT_APPEND,
+		Data: api.NewLogLine(line, format),
 	}
 }
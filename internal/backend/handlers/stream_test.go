@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"service-manager/internal/backend/api"
+	"service-manager/internal/logstore"
+	"service-manager/internal/manager"
+)
+
+// A stream that sends no initial line follows the lines already logged, so
+// the first live line is not preceded by a gap.
+func TestFollowLogsWithoutInitialLinesSendsNoGap(t *testing.T) {
+	tail := 0
+	since := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		query api.StreamQuery
+	}{
+		{name: "tail 0", query: api.StreamQuery{Tail: &tail}},
+		{name: "since after every line", query: api.StreamQuery{Since: &since}},
+		{name: "filter rejecting every line", query: api.StreamQuery{Include: "^live$"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			logsDir := filepath.Join(dir, "logs")
+			sm := manager.NewServiceManager(logsDir, filepath.Join(dir, "services.json"), logstore.RotationPolicy{}, "")
+			if err := sm.RegisterService("test", "true", nil, dir, manager.ServiceConfig{}); err != nil {
+				t.Fatalf("register service: %v", err)
+			}
+			serviceID := sm.GetAllServices()[0].ID
+
+			// Lines logged by a previous run of the manager, not in the hub
+			var logged []byte
+			for seq := uint64(1); seq <= 5; seq++ {
+				logged = append(logged, logstore.Entry{Seq: seq, Time: time.Now(), Stream: "stdout", Line: "old"}.Encode()...)
+			}
+			if err := os.MkdirAll(filepath.Join(logsDir, serviceID), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(logsDir, serviceID, "stdout"), logged, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			handler := NewStreamHandler(sm, logsDir)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			messages := make(chan api.StreamMessage, 10)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				handler.followLogs(ctx, serviceID, []string{"stdout", "stderr"}, test.query, 0, func(id uint64, msg api.StreamMessage) bool {
+					messages <- msg
+					return true
+				})
+			}()
+
+			initial := <-messages
+			if initial.Type != api.EVENT_INITIAL {
+				t.Fatalf("first message is %s, want %s", initial.Type, api.EVENT_INITIAL)
+			}
+
+			sm.LogHub().Publish(serviceID, logstore.Entry{Seq: 6, Time: time.Now(), Stream: "stdout", Line: "live"})
+
+			select {
+			case msg := <-messages:
+				if msg.Type != api.EVENT_APPEND {
+					t.Fatalf("message after the live line is %s, want %s", msg.Type, api.EVENT_APPEND)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("the live line was not sent")
+			}
+
+			cancel()
+			<-done
+		})
+	}
+}
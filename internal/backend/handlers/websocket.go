@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"service-manager/internal/backend/api"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Number of messages queued for a WebSocket client, senders wait when it is full
+	WS_SEND_BUFFER = 256
+	// Maximum size of a request sent by a WebSocket client
+	WS_MAX_REQUEST_SIZE = 64 * 1024
+	WS_WRITE_TIMEOUT    = 10 * time.Second
+	// A client is disconnected when it does not answer pings for this long
+	WS_PONG_TIMEOUT  = 60 * time.Second
+	WS_PING_INTERVAL = WS_PONG_TIMEOUT * 9 / 10
+)
+
+var upgrader = websocket.Upgrader{
+	// Any origin is allowed, like the CORS configuration of the server
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+// StreamWebSocket godoc
+// @Summary      Multiplex log streams and control services over WebSocket
+// @Description  Upgrades to a WebSocket connection carrying several log subscriptions. The client sends api.WebSocketRequest messages to subscribe to the logs of a service, unsubscribe, pause or resume a subscription, and start or stop a service. Every request is answered with an event_ack or event_error message carrying its request_id. Log messages are the ones of the SSE endpoints, carrying their subscription id. Status changes of every service are sent as event_status messages.
+// @Tags         stream
+// @Success      101  {object}  api.StreamMessage  "WebSocket messages"
+// @Router       /stream/ws [get]
+func (h *StreamHandler) StreamWebSocket(c *gin.Context) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader already replied with an error
+		log.Printf("Error upgrading to WebSocket: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	client := &webSocketClient{
+		handler:       h,
+		ctx:           ctx,
+		outgoing:      make(chan api.StreamMessage, WS_SEND_BUFFER),
+		subscriptions: make(map[string]*subscription),
+	}
+
+	go client.writeMessages(conn, cancel)
+
+	statusChanges, unsubscribe := h.ServiceManager.SubscribeStatus()
+	defer unsubscribe()
+
+	go func() {
+		for change := range statusChanges {
+			if !client.send(api.StreamMessage{Type: api.EVENT_STATUS, Data: change}) {
+				return
+			}
+		}
+	}()
+
+	client.readRequests(conn)
+}
+
+// webSocketClient is the state of a WebSocket connection.
+type webSocketClient struct {
+	handler *StreamHandler
+	// Done when the connection is closed
+	ctx context.Context
+	// Messages to write, a single goroutine writes to the connection
+	outgoing      chan api.StreamMessage
+	subscriptions map[string]*subscription
+	mutex         sync.Mutex
+}
+
+// subscription sends the logs of a service to a WebSocket client.
+type subscription struct {
+	cancel context.CancelFunc
+	// Closed when a paused subscription is resumed, nil when not paused
+	resumed chan struct{}
+	mutex   sync.Mutex
+}
+
+// send queues a message, waiting while the queue is full. It returns false
+// once the connection is closed.
+func (client *webSocketClient) send(msg api.StreamMessage) bool {
+	select {
+	case client.outgoing <- msg:
+		return true
+	case <-client.ctx.Done():
+		return false
+	}
+}
+
+func (client *webSocketClient) sendError(requestID, subscriptionID, title, detail string) {
+	client.send(api.StreamMessage{
+		Type:         api.EVENT_ERROR,
+		Data:         api.NewError(title, detail),
+		Subscription: subscriptionID,
+		RequestID:    requestID,
+	})
+}
+
+func (client *webSocketClient) sendAck(requestID, subscriptionID string, data any) {
+	client.send(api.StreamMessage{
+		Type:         api.EVENT_ACK,
+		Data:         data,
+		Subscription: subscriptionID,
+		RequestID:    requestID,
+	})
+}
+
+// writeMessages writes the queued messages and pings the client until the
+// connection is closed or a write fails.
+func (client *webSocketClient) writeMessages(conn *websocket.Conn, cancel context.CancelFunc) {
+	defer cancel()
+
+	ticker := time.NewTicker(WS_PING_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-client.ctx.Done():
+			conn.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(WS_WRITE_TIMEOUT),
+			)
+			return
+		case <-ticker.C:
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(WS_WRITE_TIMEOUT))
+			if err != nil {
+				log.Printf("Error pinging WebSocket client: %v", err)
+				return
+			}
+		case msg := <-client.outgoing:
+			conn.SetWriteDeadline(time.Now().Add(WS_WRITE_TIMEOUT))
+			if err := conn.WriteJSON(msg); err != nil {
+				// This likely means the client has closed the connection.
+				log.Printf("Error writing to WebSocket: %v", err)
+				return
+			}
+		}
+	}
+}
+
+// readRequests handles the requests of the client until the connection is
+// closed.
+func (client *webSocketClient) readRequests(conn *websocket.Conn) {
+	conn.SetReadLimit(WS_MAX_REQUEST_SIZE)
+	conn.SetReadDeadline(time.Now().Add(WS_PONG_TIMEOUT))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(WS_PONG_TIMEOUT))
+	})
+
+	for {
+		messageType, payload, err := conn.ReadMessage()
+		if err != nil {
+			log.Println("Client disconnected")
+			return
+		}
+
+		// Any message shows the client is alive
+		conn.SetReadDeadline(time.Now().Add(WS_PONG_TIMEOUT))
+
+		if messageType != websocket.TextMessage {
+			client.sendError("", "", "Bad request", "requests must be JSON text messages")
+			continue
+		}
+
+		var req api.WebSocketRequest
+		if err := binding.JSON.BindBody(payload, &req); err != nil {
+			client.sendError(req.RequestID, req.SubscriptionID, "Invalid request", err.Error())
+			continue
+		}
+
+		client.handle(req)
+	}
+}
+
+func (client *webSocketClient) handle(req api.WebSocketRequest) {
+	switch req.Action {
+	case api.WS_SUBSCRIBE:
+		client.subscribe(req)
+	case api.WS_UNSUBSCRIBE:
+		client.unsubscribe(req)
+	case api.WS_PAUSE:
+		client.pause(req, true)
+	case api.WS_RESUME:
+		client.pause(req, false)
+	case api.WS_START:
+		client.startService(req)
+	case api.WS_STOP:
+		client.stopService(req)
+	}
+}
+
+// subscribe starts sending the logs of a service under the subscription id
+// chosen by the client, after acknowledging the request.
+func (client *webSocketClient) subscribe(req api.WebSocketRequest) {
+	h := client.handler
+
+	if req.SubscriptionID == "" || req.ServiceID == "" {
+		client.sendError(req.RequestID, req.SubscriptionID, "Invalid request", "subscribe requires a subscription_id and a service_id")
+		return
+	}
+
+	if !h.ServiceManager.ServiceExists(req.ServiceID) {
+		client.sendError(req.RequestID, req.SubscriptionID, "Service does not exist", fmt.Sprintf("Could not find service with id '%s'", req.ServiceID))
+		return
+	}
+
+	streamNames := []string{"stdout", "stderr"}
+	if req.Stream == "stdout" || req.Stream == "stderr" {
+		streamNames = []string{req.Stream}
+	}
+
+	if _, err := newStreamFilter(streamNames, req.StreamQuery); err != nil {
+		client.sendError(req.RequestID, req.SubscriptionID, "Invalid filter", err.Error())
+		return
+	}
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if _, ok := client.subscriptions[req.SubscriptionID]; ok {
+		client.sendError(req.RequestID, req.SubscriptionID, "Subscription already exists", fmt.Sprintf("Subscription '%s' is in use", req.SubscriptionID))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(client.ctx)
+	sub := &subscription{cancel: cancel}
+	client.subscriptions[req.SubscriptionID] = sub
+
+	// Acknowledged before the goroutine starts, so the ack comes before the logs
+	client.sendAck(req.RequestID, req.SubscriptionID, nil)
+
+	go func() {
+		defer cancel()
+
+		h.followLogs(ctx, req.ServiceID, streamNames, req.StreamQuery, req.LastSeq, func(id uint64, msg api.StreamMessage) bool {
+			if !sub.waitResumed(ctx) {
+				return false
+			}
+
+			msg.Subscription = req.SubscriptionID
+			return client.send(msg)
+		})
+
+		client.mutex.Lock()
+		defer client.mutex.Unlock()
+
+		if client.subscriptions[req.SubscriptionID] == sub {
+			delete(client.subscriptions, req.SubscriptionID)
+		}
+	}()
+}
+
+func (client *webSocketClient) unsubscribe(req api.WebSocketRequest) {
+	client.mutex.Lock()
+	sub, ok := client.subscriptions[req.SubscriptionID]
+	delete(client.subscriptions, req.SubscriptionID)
+	client.mutex.Unlock()
+
+	if !ok {
+		client.sendError(req.RequestID, req.SubscriptionID, "Subscription does not exist", fmt.Sprintf("Could not find subscription '%s'", req.SubscriptionID))
+		return
+	}
+
+	sub.cancel()
+	client.sendAck(req.RequestID, req.SubscriptionID, nil)
+}
+
+// pause pauses or resumes a subscription. Lines captured while it is paused
+// are sent on resume, or reported as a gap if the hub evicted them meanwhile.
+func (client *webSocketClient) pause(req api.WebSocketRequest, paused bool) {
+	client.mutex.Lock()
+	sub, ok := client.subscriptions[req.SubscriptionID]
+	client.mutex.Unlock()
+
+	if !ok {
+		client.sendError(req.RequestID, req.SubscriptionID, "Subscription does not exist", fmt.Sprintf("Could not find subscription '%s'", req.SubscriptionID))
+		return
+	}
+
+	sub.mutex.Lock()
+	if paused && sub.resumed == nil {
+		sub.resumed = make(chan struct{})
+	} else if !paused && sub.resumed != nil {
+		close(sub.resumed)
+		sub.resumed = nil
+	}
+	sub.mutex.Unlock()
+
+	client.sendAck(req.RequestID, req.SubscriptionID, nil)
+}
+
+// waitResumed waits while the subscription is paused. It returns false if the
+// context is done first.
+func (sub *subscription) waitResumed(ctx context.Context) bool {
+	sub.mutex.Lock()
+	resumed := sub.resumed
+	sub.mutex.Unlock()
+
+	if resumed == nil {
+		return true
+	}
+
+	select {
+	case <-resumed:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// startService starts a service in the background, as it may wait for its
+// dependencies, and answers the request once it is started.
+func (client *webSocketClient) startService(req api.WebSocketRequest) {
+	h := client.handler
+
+	go func() {
+		if err := h.ServiceManager.StartService(req.ServiceID); err != nil {
+			client.sendError(req.RequestID, "", "failed to start service", err.Error())
+			return
+		}
+
+		client.sendAck(req.RequestID, "", gin.H{"message": "service started"})
+	}()
+}
+
+// stopService stops a service in the background, as it may wait for the stop
+// timeout, and answers the request once it is stopped.
+func (client *webSocketClient) stopService(req api.WebSocketRequest) {
+	h := client.handler
+
+	var gracePeriod *time.Duration
+	if req.TimeoutSeconds != nil {
+		timeout := time.Duration(*req.TimeoutSeconds * float64(time.Second))
+		gracePeriod = &timeout
+	}
+
+	go func() {
+		graceful, err := h.ServiceManager.StopService(req.ServiceID, gracePeriod)
+		if err != nil {
+			client.sendError(req.RequestID, "", "Failed to stop service", err.Error())
+			return
+		}
+
+		client.sendAck(req.RequestID, "", gin.H{"message": "service stopped", "graceful": graceful})
+	}()
+}
@@ -16,32 +16,48 @@ func BindOrAbort[T any](c *gin.Context) (T, bool) {
 
 	// We must pass a pointer to ShouldBindJSON so it can modify 'req'.
 	if err := c.ShouldBindJSON(&req); err != nil {
-		var validationErrs validator.ValidationErrors
-		if errors.As(err, &validationErrs) {
-			// Validation error (e.g., missing required field).
-			apiError := api.NewError(
-				"Invalid request schema",
-				"One or many fields of the request is not correct",
-			)
-			c.JSON(
-				http.StatusUnprocessableEntity,
-				apiError,
-			)
-			return req, false
-		}
+		abortBindError(c, err)
+		return req, false
+	}
+
+	return req, true
+}
+
+// BindQueryOrAbort binds and validates the query parameters of the request,
+// failing the same way as BindOrAbort.
+func BindQueryOrAbort[T any](c *gin.Context) (T, bool) {
+	var req T
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		abortBindError(c, err)
+		return req, false
+	}
+
+	return req, true
+}
 
+func abortBindError(c *gin.Context, err error) {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		// Validation error (e.g., missing required field).
 		apiError := api.NewError(
-			"Bad request",
-			err.Error(),
+			"Invalid request schema",
+			"One or many fields of the request is not correct",
 		)
 		c.JSON(
-			http.StatusBadRequest,
+			http.StatusUnprocessableEntity,
 			apiError,
 		)
-
-		// Syntax error (e.g., malformed JSON).
-		return req, false
+		return
 	}
 
-	return req, true
+	// Syntax error (e.g., malformed JSON).
+	apiError := api.NewError(
+		"Bad request",
+		err.Error(),
+	)
+	c.JSON(
+		http.StatusBadRequest,
+		apiError,
+	)
 }
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"service-manager/internal/backend/handlers"
+	"service-manager/internal/manager"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterLogsRoutes(router *gin.Engine, sm *manager.ServiceManager, logsDir string) {
+	handler := handlers.NewLogsHandler(sm, logsDir)
+
+	logsGroup := router.Group("/logs")
+	{
+		logsGroup.GET("/:serviceID", handler.SearchLogs)
+	}
+}
@@ -14,11 +14,14 @@ func RegisterServiceManagerRoutes(router *gin.Engine, sm *manager.ServiceManager
 	{
 		serviceManagerGroup.POST("/register", handler.RegisterService)
 		serviceManagerGroup.GET("/services", handler.GetServices)
+		serviceManagerGroup.GET("/services/:serviceID/runs", handler.GetServiceRuns)
 		serviceManagerGroup.POST("/start", handler.StartService)
 		serviceManagerGroup.POST("/stop", handler.StopService)
 		serviceManagerGroup.DELETE("/remove", handler.RemoveService)
 		serviceManagerGroup.POST("/metrics", handler.GetServiceMetrics)
 		serviceManagerGroup.POST("/network", handler.GetNetworkInfo)
+		serviceManagerGroup.POST("/tty/resize", handler.ResizeTTY)
+		serviceManagerGroup.GET("/startup", handler.GetStartupSummary)
 	}
 
 }
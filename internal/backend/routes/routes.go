@@ -15,6 +15,7 @@ func RegisterRoutes(router *gin.Engine, sm *manager.ServiceManager, logsDir stri
 
 	RegisterServiceManagerRoutes(router, sm)
 	RegisterStreamRoutes(router, sm, logsDir)
+	RegisterLogsRoutes(router, sm, logsDir)
 
 	// Redirect /docs to /docs/
 	router.GET("/docs", func(c *gin.Context) {
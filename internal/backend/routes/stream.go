@@ -14,5 +14,7 @@ func RegisterStreamRoutes(router *gin.Engine, sm *manager.ServiceManager, logsDi
 	{
 		streamGroup.GET("/stdout/:serviceID", handler.StreamStdout)
 		streamGroup.GET("/stderr/:serviceID", handler.StreamStderr)
+		streamGroup.GET("/combined/:serviceID", handler.StreamCombined)
+		streamGroup.GET("/ws", handler.StreamWebSocket)
 	}
 }
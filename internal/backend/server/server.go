@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/signal"
 	"service-manager/internal/backend/routes"
+	"service-manager/internal/logstore"
 	"service-manager/internal/manager"
 	"time"
 
@@ -22,16 +23,24 @@ type Server struct {
 	Port           string
 }
 
-func NewServer(logsDir, servicesDataPath, host, port string) (*Server, error) {
+func NewServer(
+	logsDir, servicesDataPath, host, port string,
+	autostartStagger time.Duration,
+	logRotation logstore.RotationPolicy,
+	cgroupSlice string,
+) (*Server, error) {
 	// Server startup logics here
 
-	serviceManager := manager.NewServiceManager(logsDir, servicesDataPath)
+	serviceManager := manager.NewServiceManager(logsDir, servicesDataPath, logRotation, cgroupSlice)
 
 	err := serviceManager.LoadServices()
 	if err != nil {
 		log.Printf("could not load services from file: %v", err)
 	}
 
+	// Start in the background so the API is available while services come up
+	go serviceManager.AutostartServices(autostartStagger)
+
 	router := gin.Default()
 	router.Use(cors.Default()) // Allow all origin
 	router.HandleMethodNotAllowed = true
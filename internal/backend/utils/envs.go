@@ -3,6 +3,7 @@ package utils
 import (
 	"log"
 	"os"
+	"strconv"
 )
 
 // GetEnv returns the value of the environment variable 'key'.
@@ -15,3 +16,29 @@ func GetEnv(key string, defaultValue string) string {
 	}
 	return value
 }
+
+// GetEnvFloat returns the environment variable 'key' parsed as a float.
+// If the variable is not set or invalid, it returns 'defaultValue' and logs a message.
+func GetEnvFloat(key string, defaultValue float64) float64 {
+	value := GetEnv(key, strconv.FormatFloat(defaultValue, 'f', -1, 64))
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Environment variable '%s' is not a number, using default value '%v'", key, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// GetEnvInt returns the environment variable 'key' parsed as an integer.
+// If the variable is not set or invalid, it returns 'defaultValue' and logs a message.
+func GetEnvInt(key string, defaultValue int64) int64 {
+	value := GetEnv(key, strconv.FormatInt(defaultValue, 10))
+
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Printf("Environment variable '%s' is not an integer, using default value '%d'", key, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
@@ -1,65 +1,37 @@
 package utils
 
 import (
-	"bufio"
-	"os"
-	"strings"
+	"service-manager/internal/logstore"
+	"slices"
+	"time"
 )
 
-func ReadLines(filePath string, numberOfLines int) ([]string, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
+// ReadLastLines reads the last lines of a log kept by keep, from its active
+// file back through its rotated segments. When before is not 0 only lines with
+// a lower sequence number are read, and reading stops at the first line older
+// than since. The lines are returned oldest first.
+func ReadLastLines(filePath string, numberOfLines int, before uint64, since time.Time, keep func(entry logstore.Entry) bool) ([]logstore.Entry, error) {
+	lines := make([]logstore.Entry, 0, numberOfLines)
+	if numberOfLines == 0 {
+		return lines, nil
 	}
-	defer file.Close()
-
-	lines := make([]string, 0, numberOfLines)
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		trimmedLine := strings.TrimSpace(scanner.Text())
-		lines = append(lines, trimmedLine)
-		if len(lines) >= numberOfLines {
-			break
+	err := logstore.ScanBackward(filePath, func(entry logstore.Entry) bool {
+		if !since.IsZero() && entry.Time.Before(since) {
+			return false
 		}
-	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
-
-	return lines, nil
-}
-
-func ReadLastLine(path string) (string, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
+		if (before == 0 || entry.Seq < before) && keep(entry) {
+			lines = append(lines, entry)
+		}
 
-	fileInfo, err := file.Stat()
+		return len(lines) < numberOfLines
+	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	var line []byte
-	var buf [1]byte
-	pos := fileInfo.Size() - 1
-
-	for pos >= 0 {
-		_, err := file.ReadAt(buf[:], pos)
-		if err != nil {
-			return "", err
-		}
-
-		if buf[0] == '\n' && pos != fileInfo.Size()-1 {
-			break
-		}
+	slices.Reverse(lines)
 
-		line = append([]byte{buf[0]}, line...)
-		pos--
-	}
-
-	return strings.TrimRight(string(line), "\n"), nil
+	return lines, nil
 }
@@ -0,0 +1,240 @@
+package logstore
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ANSI escape sequences: CSI sequences such as colours and cursor moves, with
+// their parameters and final byte captured, OSC sequences such as window titles
+// and links, and two character escapes.
+var ansiSequencePattern = regexp.MustCompile(`\x1b(?:\[([0-?]*)[ -/]*([@-~])|\][^\x07\x1b]*(?:\x07|\x1b\\)?|[@-Z\\-_])`)
+
+// Colours of the 16 colour palette, as xterm shows them
+var ansiPalette = [16]string{
+	"#000000", "#cd0000", "#00cd00", "#cdcd00", "#0000ee", "#cd00cd", "#00cdcd", "#e5e5e5",
+	"#7f7f7f", "#ff0000", "#00ff00", "#ffff00", "#5c5cff", "#ff00ff", "#00ffff", "#ffffff",
+}
+
+// StripANSI removes the ANSI escape sequences of a line.
+func StripANSI(line string) string {
+	if !strings.Contains(line, "\x1b") {
+		return line
+	}
+	return ansiSequencePattern.ReplaceAllString(line, "")
+}
+
+// ANSIToHTML escapes a line for HTML and turns its colour and style sequences
+// into spans with inline styles. Other escape sequences are removed.
+func ANSIToHTML(line string) string {
+	var out strings.Builder
+	var style sgrStyle
+	open := false
+	last := 0
+
+	for _, match := range ansiSequencePattern.FindAllStringSubmatchIndex(line, -1) {
+		out.WriteString(html.EscapeString(line[last:match[0]]))
+		last = match[1]
+
+		// Only Select Graphic Rendition sequences, ending with m, change the style
+		if match[4] < 0 || line[match[4]:match[5]] != "m" {
+			continue
+		}
+
+		style.apply(line[match[2]:match[3]])
+
+		if open {
+			out.WriteString("</span>")
+			open = false
+		}
+		if css := style.css(); css != "" {
+			fmt.Fprintf(&out, `<span style="%s">`, css)
+			open = true
+		}
+	}
+
+	out.WriteString(html.EscapeString(line[last:]))
+	if open {
+		out.WriteString("</span>")
+	}
+
+	return out.String()
+}
+
+// CollapseCarriageReturns returns the text last written over a line that is
+// rewritten with carriage returns, such as a progress bar: the text after the
+// last carriage return, or before it if nothing follows. Each line of a
+// multiline record is collapsed on its own.
+func CollapseCarriageReturns(line string) string {
+	if !strings.Contains(line, "\r") {
+		return line
+	}
+
+	if strings.Contains(line, "\n") {
+		lines := strings.Split(line, "\n")
+		for i := range lines {
+			lines[i] = CollapseCarriageReturns(lines[i])
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	segments := strings.Split(line, "\r")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if segments[i] != "" {
+			return segments[i]
+		}
+	}
+
+	return ""
+}
+
+// sgrStyle is the text style set by Select Graphic Rendition sequences.
+type sgrStyle struct {
+	foreground    string
+	background    string
+	bold          bool
+	dim           bool
+	italic        bool
+	underline     bool
+	strikethrough bool
+}
+
+// apply updates the style with the semicolon separated parameters of a
+// sequence. Unsupported parameters are ignored.
+func (s *sgrStyle) apply(parameters string) {
+	codes := strings.Split(parameters, ";")
+
+	for i := 0; i < len(codes); i++ {
+		// An empty parameter means 0
+		code, _ := strconv.Atoi(codes[i])
+
+		switch {
+		case code == 0:
+			*s = sgrStyle{}
+		case code == 1:
+			s.bold = true
+		case code == 2:
+			s.dim = true
+		case code == 3:
+			s.italic = true
+		case code == 4:
+			s.underline = true
+		case code == 9:
+			s.strikethrough = true
+		case code == 22:
+			s.bold = false
+			s.dim = false
+		case code == 23:
+			s.italic = false
+		case code == 24:
+			s.underline = false
+		case code == 29:
+			s.strikethrough = false
+		case code >= 30 && code <= 37:
+			s.foreground = ansiPalette[code-30]
+		case code == 38:
+			s.foreground, i = extendedColor(codes, i)
+		case code == 39:
+			s.foreground = ""
+		case code >= 40 && code <= 47:
+			s.background = ansiPalette[code-40]
+		case code == 48:
+			s.background, i = extendedColor(codes, i)
+		case code == 49:
+			s.background = ""
+		case code >= 90 && code <= 97:
+			s.foreground = ansiPalette[code-90+8]
+		case code >= 100 && code <= 107:
+			s.background = ansiPalette[code-100+8]
+		}
+	}
+}
+
+// extendedColor parses the 256 colour (38;5;n) or true colour (38;2;r;g;b)
+// parameters following codes[i]. It returns the colour, empty if invalid, and
+// the index of the last parameter used.
+func extendedColor(codes []string, i int) (string, int) {
+	if i+1 >= len(codes) {
+		return "", i
+	}
+
+	switch codes[i+1] {
+	case "5":
+		if i+2 >= len(codes) {
+			return "", len(codes)
+		}
+		n, err := strconv.Atoi(codes[i+2])
+		if err != nil || n < 0 || n > 255 {
+			return "", i + 2
+		}
+		return color256(n), i + 2
+	case "2":
+		if i+4 >= len(codes) {
+			return "", len(codes)
+		}
+		var rgb [3]int
+		for j := range rgb {
+			value, err := strconv.Atoi(codes[i+2+j])
+			if err != nil || value < 0 || value > 255 {
+				return "", i + 4
+			}
+			rgb[j] = value
+		}
+		return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2]), i + 4
+	}
+
+	return "", i + 1
+}
+
+// color256 returns a colour of the 256 colour palette: the 16 colour palette,
+// a 6x6x6 colour cube and a grey ramp.
+func color256(n int) string {
+	if n < 16 {
+		return ansiPalette[n]
+	}
+
+	if n >= 232 {
+		grey := 8 + (n-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", grey, grey, grey)
+	}
+
+	levels := [6]int{0, 95, 135, 175, 215, 255}
+	n -= 16
+	return fmt.Sprintf("#%02x%02x%02x", levels[n/36], levels[n/6%6], levels[n%6])
+}
+
+func (s sgrStyle) css() string {
+	var declarations []string
+
+	if s.foreground != "" {
+		declarations = append(declarations, "color:"+s.foreground)
+	}
+	if s.background != "" {
+		declarations = append(declarations, "background-color:"+s.background)
+	}
+	if s.bold {
+		declarations = append(declarations, "font-weight:bold")
+	}
+	if s.dim {
+		declarations = append(declarations, "opacity:0.7")
+	}
+	if s.italic {
+		declarations = append(declarations, "font-style:italic")
+	}
+
+	var decorations []string
+	if s.underline {
+		decorations = append(decorations, "underline")
+	}
+	if s.strikethrough {
+		decorations = append(decorations, "line-through")
+	}
+	if len(decorations) > 0 {
+		declarations = append(declarations, "text-decoration:"+strings.Join(decorations, " "))
+	}
+
+	return strings.Join(declarations, ";")
+}
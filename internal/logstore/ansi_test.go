@@ -0,0 +1,76 @@
+package logstore
+
+import "testing"
+
+func TestStripANSI(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{line: "plain  text\t", want: "plain  text\t"},
+		{line: "\x1b[31mred\x1b[0m plain", want: "red plain"},
+		{line: "\x1b[2K\x1b[1Gprogress", want: "progress"},
+		{line: "\x1b]0;title\x07text", want: "text"},
+		{line: "\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\", want: "link"},
+	}
+
+	for _, test := range tests {
+		if got := StripANSI(test.line); got != test.want {
+			t.Errorf("StripANSI(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+}
+
+func TestANSIToHTML(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{line: "<a> & b", want: "&lt;a&gt; &amp; b"},
+		{line: "\x1b[31mred\x1b[0m plain", want: `<span style="color:#cd0000">red</span> plain`},
+		{line: "\x1b[1;32mbold green", want: `<span style="color:#00cd00;font-weight:bold">bold green</span>`},
+		{line: "\x1b[4m<u>", want: `<span style="text-decoration:underline">&lt;u&gt;</span>`},
+		{line: "\x1b[38;5;208m256", want: `<span style="color:#ff8700">256</span>`},
+		{line: "\x1b[38;2;1;2;3mrgb", want: `<span style="color:#010203">rgb</span>`},
+		{line: "\x1b[2Kcleared", want: "cleared"},
+	}
+
+	for _, test := range tests {
+		if got := ANSIToHTML(test.line); got != test.want {
+			t.Errorf("ANSIToHTML(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+}
+
+func TestCollapseCarriageReturns(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{line: "no carriage return", want: "no carriage return"},
+		{line: "10%\r50%\r100%", want: "100%"},
+		{line: "done\r", want: "done"},
+		{line: "a\rb\nc\rd", want: "b\nd"},
+		{line: "\r", want: ""},
+	}
+
+	for _, test := range tests {
+		if got := CollapseCarriageReturns(test.line); got != test.want {
+			t.Errorf("CollapseCarriageReturns(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+}
+
+func TestEntryEncodeKeepsBytes(t *testing.T) {
+	for _, line := range []string{"  indented\t", "\x1b[31mred\x1b[0m", "progress\r", "invalid \xff\xfe utf-8", ""} {
+		encoded := Entry{Seq: 1, Stream: "stdout", Line: line}.Encode()
+
+		entry, ok := decodeEntry(trimLineEnding(encoded))
+		if !ok {
+			t.Fatalf("%q was not decoded", encoded)
+		}
+		if entry.Line != line {
+			t.Errorf("line %q was stored as %q", line, entry.Line)
+		}
+	}
+}
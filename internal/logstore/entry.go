@@ -0,0 +1,121 @@
+package logstore
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Prefix of every encoded entry, used to tell entries from legacy plain-text lines
+var entryPrefix = []byte(`{"seq":`)
+
+// Entry is a captured log line. Log files store one JSON encoded entry per line.
+type Entry struct {
+	// Increases monotonically across the streams of a service, 0 for legacy lines
+	Seq uint64
+	// Capture time, zero for legacy lines
+	Time   time.Time
+	Stream string
+	// The bytes of the line as captured, without its line break
+	Line string
+}
+
+// record is the stored form of an entry. JSON strings only hold valid UTF-8,
+// so lines that are not are stored base64 encoded in Raw, with Line holding
+// them with invalid bytes replaced.
+type record struct {
+	Seq    uint64    `json:"seq"`
+	Time   time.Time `json:"time"`
+	Stream string    `json:"stream"`
+	Line   string    `json:"line"`
+	Raw    []byte    `json:"raw,omitempty"`
+}
+
+// Encode returns the entry as a line of a log file.
+func (e Entry) Encode() []byte {
+	stored := record{
+		Seq:    e.Seq,
+		Time:   e.Time,
+		Stream: e.Stream,
+		Line:   e.Line,
+	}
+
+	if !utf8.ValidString(e.Line) {
+		stored.Line = strings.ToValidUTF8(e.Line, string(utf8.RuneError))
+		stored.Raw = []byte(e.Line)
+	}
+
+	encoded, err := json.Marshal(stored)
+	if err != nil {
+		// Marshalling a struct of strings, numbers and time cannot fail
+		panic(err)
+	}
+	return append(encoded, '\n')
+}
+
+// decodeEntry decodes a line of a log file, reporting false if it is not an entry.
+func decodeEntry(raw []byte) (Entry, bool) {
+	if !bytes.HasPrefix(raw, entryPrefix) {
+		return Entry{}, false
+	}
+
+	var stored record
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return Entry{}, false
+	}
+
+	entry := Entry{
+		Seq:    stored.Seq,
+		Time:   stored.Time,
+		Stream: stored.Stream,
+		Line:   stored.Line,
+	}
+	if stored.Raw != nil {
+		entry.Line = string(stored.Raw)
+	}
+
+	return entry, true
+}
+
+// trimLineEnding removes the line break ending a line read from a log file.
+func trimLineEnding(line []byte) []byte {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	return bytes.TrimSuffix(line, []byte("\r"))
+}
+
+// ParseEntry decodes a line of a log file. Lines written before entries were
+// introduced are plain text, they are returned as the line of the given stream
+// without a sequence number or time.
+func ParseEntry(raw []byte, stream string) Entry {
+	if entry, ok := decodeEntry(raw); ok {
+		return entry
+	}
+
+	return Entry{
+		Stream: stream,
+		Line:   string(raw),
+	}
+}
+
+// MergeEntries merges the entries of several streams in capture order. Legacy
+// lines have no sequence number and come first, in their original order.
+func MergeEntries(streams ...[]Entry) []Entry {
+	if len(streams) == 1 {
+		return streams[0]
+	}
+
+	var merged []Entry
+	for _, entries := range streams {
+		merged = append(merged, entries...)
+	}
+
+	slices.SortStableFunc(merged, func(a, b Entry) int {
+		return cmp.Compare(a.Seq, b.Seq)
+	})
+
+	return merged
+}
@@ -0,0 +1,202 @@
+package logstore
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Size of the write buffer of a log file
+const FILE_BUFFER_SIZE = 64 * 1024
+
+// File is an append-only log file rotated according to a policy. The file
+// handle stays open and writes are buffered until Flush, which also rotates a
+// file that outlived the age limit. Rotated segments are compressed in the
+// background.
+type File struct {
+	path   string
+	policy RotationPolicy
+	file   *os.File
+	buffer *bufio.Writer
+	size   int64
+	// When the first line of the file was written
+	createdAt time.Time
+	mutex     sync.Mutex
+}
+
+func NewFile(path string, policy RotationPolicy) *File {
+	return &File{
+		path:   path,
+		policy: policy,
+	}
+}
+
+func (f *File) Path() string {
+	return f.path
+}
+
+func openAppend(filePath string) (*os.File, error) {
+	// Flags to open file in append mode, if file is not exist then create and open
+	// in append mode
+	parentDir := filepath.Dir(filePath)
+
+	if err := os.MkdirAll(parentDir, 0755); err != nil {
+		return nil, fmt.Errorf("create parent dir: %w", err)
+	}
+
+	flags := os.O_APPEND | os.O_CREATE | os.O_WRONLY
+
+	return os.OpenFile(filePath, flags, 0644)
+}
+
+func (f *File) open() error {
+	file, err := openAppend(f.path)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("stat log file: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	if f.size > 0 {
+		f.createdAt = firstEntryTime(f.path)
+	}
+
+	if f.buffer == nil {
+		f.buffer = bufio.NewWriterSize(file, FILE_BUFFER_SIZE)
+	} else {
+		f.buffer.Reset(file)
+	}
+
+	return nil
+}
+
+// firstEntryTime returns the capture time of the first line of a log file. The
+// creation time of a file is not available portably, and its modification time
+// is when its newest line was written. It is zero for a file starting with a
+// legacy line, which is older than any entry, or that cannot be read.
+func firstEntryTime(path string) time.Time {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}
+	}
+	defer file.Close()
+
+	line, _ := bufio.NewReader(file).ReadBytes('\n')
+	entry, _ := decodeEntry(trimLineEnding(line))
+
+	return entry.Time
+}
+
+// Write appends p to the file, rotating it first if the policy requires it.
+func (f *File) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if f.policy.shouldRotate(f.size, f.createdAt, len(p)) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if f.size == 0 {
+		f.createdAt = time.Now()
+	}
+
+	n, err := f.buffer.Write(p)
+	f.size += int64(n)
+
+	return n, err
+}
+
+// Flush writes the buffered lines to the file, then rotates it if it is older
+// than the policy allows, so that a service that stopped writing does not keep
+// its lines in the active file. The next Write opens a new file.
+func (f *File) Flush() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	if err := f.buffer.Flush(); err != nil {
+		return err
+	}
+
+	if f.policy.shouldRotate(f.size, f.createdAt, 0) {
+		return f.rotate()
+	}
+
+	return nil
+}
+
+func (f *File) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.close()
+}
+
+func (f *File) close() error {
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.buffer.Flush()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	f.file = nil
+
+	return err
+}
+
+// rotate moves the active file to a new segment and compresses it in the background.
+func (f *File) rotate() error {
+	if err := f.close(); err != nil {
+		log.Printf("failed to close log file %s before rotation: %v", f.path, err)
+	}
+
+	segment := segmentPath(f.path, time.Now())
+
+	if err := os.Rename(f.path, segment); err != nil {
+		return fmt.Errorf("rotate log file: %w", err)
+	}
+
+	policy := f.policy
+	go func() {
+		retentionMutex := directoryRetentionMutex(filepath.Dir(f.path))
+		retentionMutex.Lock()
+		defer retentionMutex.Unlock()
+
+		if err := compressSegment(segment); err != nil {
+			log.Printf("failed to compress log segment %s: %v", segment, err)
+		}
+
+		if err := applyRetention(f.path, policy); err != nil {
+			log.Printf("failed to apply log retention to %s: %v", f.path, err)
+		}
+	}()
+
+	return nil
+}
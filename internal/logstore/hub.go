@@ -0,0 +1,174 @@
+package logstore
+
+import (
+	"slices"
+	"sort"
+	"sync"
+)
+
+const (
+	// Number of latest entries of a service kept for live subscribers
+	HUB_BUFFER_SIZE = 10000
+	// Total length of the lines of a service kept for live subscribers, as
+	// lines and multiline records can be several MiB long
+	HUB_BUFFER_BYTES = 8 * 1024 * 1024
+)
+
+// Hub broadcasts the entries captured from services to live subscribers. The
+// latest entries of every service are kept in a buffer, bounded by a number of
+// entries and of bytes, that subscribers pull from with a sequence number
+// cursor, so a slow subscriber never blocks capture. A subscriber that falls
+// behind the buffer is told what it missed.
+type Hub struct {
+	bufferSize  int
+	bufferBytes int
+	topics      map[string]*topic
+	mutex       sync.Mutex
+}
+
+// topic holds the buffered entries of one service.
+type topic struct {
+	// Oldest first, the entries before start were evicted
+	entries []Entry
+	start   int
+	// Total length of the lines of the buffered entries
+	bytes int
+	// Closed when the next entry is published, created when a subscriber waits
+	notify chan struct{}
+	mutex  sync.Mutex
+}
+
+// Gap is a range of sequence numbers evicted from the buffer before a
+// subscriber read them.
+type Gap struct {
+	From uint64
+	To   uint64
+}
+
+func NewHub(bufferSize, bufferBytes int) *Hub {
+	return &Hub{
+		bufferSize:  bufferSize,
+		bufferBytes: bufferBytes,
+		topics:      make(map[string]*topic),
+	}
+}
+
+func (h *Hub) topic(serviceID string) *topic {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	t, ok := h.topics[serviceID]
+	if !ok {
+		t = &topic{}
+		h.topics[serviceID] = t
+	}
+
+	return t
+}
+
+// Publish adds an entry of a service and wakes up its subscribers. The entries
+// of a service must be published in sequence order.
+func (h *Hub) Publish(serviceID string, entry Entry) {
+	t := h.topic(serviceID)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.entries = append(t.entries, entry)
+	t.bytes += len(entry.Line)
+
+	// The oldest entries are evicted first, the latest one is kept even if it
+	// is longer than the budget
+	for len(t.entries)-t.start > 1 && (len(t.entries)-t.start > h.bufferSize || t.bytes > h.bufferBytes) {
+		t.bytes -= len(t.entries[t.start].Line)
+		t.entries[t.start] = Entry{}
+		t.start++
+	}
+
+	// The evicted entries are dropped once they make up half of the slice
+	if t.start > len(t.entries)/2 {
+		count := copy(t.entries, t.entries[t.start:])
+		clear(t.entries[count:])
+		t.entries = t.entries[:count]
+		t.start = 0
+	}
+
+	if t.notify != nil {
+		close(t.notify)
+		t.notify = nil
+	}
+}
+
+// Oldest returns the sequence number of the oldest buffered entry of a
+// service, or 0 if none is buffered. Older entries are only in the log files.
+func (h *Hub) Oldest(serviceID string) uint64 {
+	h.mutex.Lock()
+	t, ok := h.topics[serviceID]
+	h.mutex.Unlock()
+
+	if !ok {
+		return 0
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.start == len(t.entries) {
+		return 0
+	}
+
+	return t.entries[t.start].Seq
+}
+
+// Read returns up to limit buffered entries of a service with a sequence number
+// greater than after, oldest first, and a channel closed when the next entry is
+// published. If entries following after were evicted already, the range missed
+// is returned with the oldest buffered entries.
+func (h *Hub) Read(serviceID string, after uint64, limit int) ([]Entry, *Gap, <-chan struct{}) {
+	t := h.topic(serviceID)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.notify == nil {
+		t.notify = make(chan struct{})
+	}
+
+	buffered := t.entries[t.start:]
+	if len(buffered) == 0 {
+		return nil, nil, t.notify
+	}
+
+	var gap *Gap
+	if oldest := buffered[0].Seq; after+1 < oldest {
+		gap = &Gap{From: after + 1, To: oldest - 1}
+	}
+
+	first := sort.Search(len(buffered), func(i int) bool {
+		return buffered[i].Seq > after
+	})
+
+	entries := buffered[first:min(first+limit, len(buffered))]
+
+	return slices.Clone(entries), gap, t.notify
+}
+
+// Remove drops the buffered entries of a service and wakes up its subscribers.
+func (h *Hub) Remove(serviceID string) {
+	h.mutex.Lock()
+	t, ok := h.topics[serviceID]
+	delete(h.topics, serviceID)
+	h.mutex.Unlock()
+
+	if !ok {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.notify != nil {
+		close(t.notify)
+		t.notify = nil
+	}
+}
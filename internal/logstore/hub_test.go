@@ -0,0 +1,152 @@
+package logstore
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestHubRead(t *testing.T) {
+	tests := []struct {
+		name        string
+		bufferSize  int
+		bufferBytes int
+		// Sequence numbers published, with lines of lineBytes bytes
+		published []uint64
+		lineBytes int
+		after     uint64
+		limit     int
+		want      []uint64
+		wantGap   *Gap
+	}{
+		{
+			name:       "empty",
+			bufferSize: 4, bufferBytes: 1024,
+			after: 0, limit: 10,
+		},
+		{
+			name:       "after the start",
+			bufferSize: 4, bufferBytes: 1024,
+			published: []uint64{1, 2, 3}, lineBytes: 1,
+			after: 0, limit: 10,
+			want: []uint64{1, 2, 3},
+		},
+		{
+			name:       "after a buffered entry",
+			bufferSize: 4, bufferBytes: 1024,
+			published: []uint64{1, 2, 3}, lineBytes: 1,
+			after: 1, limit: 10,
+			want: []uint64{2, 3},
+		},
+		{
+			name:       "limited",
+			bufferSize: 4, bufferBytes: 1024,
+			published: []uint64{1, 2, 3}, lineBytes: 1,
+			after: 0, limit: 2,
+			want: []uint64{1, 2},
+		},
+		{
+			name:       "up to date",
+			bufferSize: 4, bufferBytes: 1024,
+			published: []uint64{1, 2, 3}, lineBytes: 1,
+			after: 3, limit: 10,
+		},
+		{
+			name:       "evicted by count",
+			bufferSize: 3, bufferBytes: 1024,
+			published: []uint64{1, 2, 3, 4, 5, 6}, lineBytes: 1,
+			after: 1, limit: 10,
+			want:    []uint64{4, 5, 6},
+			wantGap: &Gap{From: 2, To: 3},
+		},
+		{
+			name:       "evicted by bytes",
+			bufferSize: 100, bufferBytes: 25,
+			published: []uint64{1, 2, 3, 4, 5, 6}, lineBytes: 10,
+			after: 0, limit: 10,
+			want:    []uint64{5, 6},
+			wantGap: &Gap{From: 1, To: 4},
+		},
+		{
+			name:       "latest entry kept over the byte budget",
+			bufferSize: 100, bufferBytes: 25,
+			published: []uint64{1, 2, 3}, lineBytes: 100,
+			after: 0, limit: 10,
+			want:    []uint64{3},
+			wantGap: &Gap{From: 1, To: 2},
+		},
+		{
+			name:       "sequence numbers starting after 1",
+			bufferSize: 4, bufferBytes: 1024,
+			published: []uint64{41, 42}, lineBytes: 1,
+			after: 40, limit: 10,
+			want: []uint64{41, 42},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hub := NewHub(test.bufferSize, test.bufferBytes)
+			for _, seq := range test.published {
+				hub.Publish("service", Entry{Seq: seq, Line: strings.Repeat("x", test.lineBytes)})
+			}
+
+			entries, gap, _ := hub.Read("service", test.after, test.limit)
+
+			var got []uint64
+			for _, entry := range entries {
+				got = append(got, entry.Seq)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("read %v, want %v", got, test.want)
+			}
+
+			if (gap == nil) != (test.wantGap == nil) || (gap != nil && *gap != *test.wantGap) {
+				t.Errorf("gap %v, want %v", gap, test.wantGap)
+			}
+		})
+	}
+}
+
+func TestHubEvictionKeepsOrder(t *testing.T) {
+	hub := NewHub(10, 1024)
+	for seq := uint64(1); seq <= 1000; seq++ {
+		hub.Publish("service", Entry{Seq: seq, Line: "x"})
+
+		want := uint64(1)
+		if seq > 10 {
+			want = seq - 9
+		}
+		if oldest := hub.Oldest("service"); oldest != want {
+			t.Fatalf("oldest is %d after publishing %d, want %d", oldest, seq, want)
+		}
+	}
+
+	entries, _, _ := hub.Read("service", 990, 100)
+	for i, entry := range entries {
+		if entry.Seq != uint64(991+i) {
+			t.Fatalf("entry %d is %d, want %d", i, entry.Seq, 991+i)
+		}
+	}
+}
+
+func TestHubRemove(t *testing.T) {
+	hub := NewHub(10, 1024)
+	hub.Publish("service", Entry{Seq: 1, Line: "x"})
+
+	_, _, notify := hub.Read("service", 1, 10)
+	hub.Remove("service")
+
+	select {
+	case <-notify:
+	default:
+		t.Fatal("subscribers were not woken up")
+	}
+
+	if oldest := hub.Oldest("service"); oldest != 0 {
+		t.Fatalf("oldest is %d after remove, want 0", oldest)
+	}
+	if _, ok := hub.topics["service"]; ok {
+		t.Fatal("the topic of the removed service is kept")
+	}
+}
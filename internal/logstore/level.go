@@ -0,0 +1,84 @@
+package logstore
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Level is the severity of a log line, detected from its text.
+type Level int
+
+const (
+	LEVEL_UNKNOWN Level = iota
+	LEVEL_TRACE
+	LEVEL_DEBUG
+	LEVEL_INFO
+	LEVEL_WARN
+	LEVEL_ERROR
+	LEVEL_FATAL
+)
+
+var levelNames = map[string]Level{
+	"trace":    LEVEL_TRACE,
+	"debug":    LEVEL_DEBUG,
+	"info":     LEVEL_INFO,
+	"warn":     LEVEL_WARN,
+	"warning":  LEVEL_WARN,
+	"error":    LEVEL_ERROR,
+	"err":      LEVEL_ERROR,
+	"fatal":    LEVEL_FATAL,
+	"panic":    LEVEL_FATAL,
+	"critical": LEVEL_FATAL,
+	"crit":     LEVEL_FATAL,
+}
+
+var (
+	// level=error, "level":"error", severity: ERROR and the like
+	structuredLevelPattern = regexp.MustCompile(`(?i)"?\b(?:level|lvl|severity)"?\s*[=:]\s*"?([a-z]+)`)
+	// ERROR, [error] or <error> as a word of its own
+	wordLevelPattern = regexp.MustCompile(`\b(TRACE|DEBUG|INFO|WARN|WARNING|ERROR|FATAL|PANIC|CRITICAL|CRIT)\b|[\[<](?i:(trace|debug|info|warn|warning|error|err|fatal|panic|critical|crit))[\]>]`)
+	// Lines starting a Go panic or a Python traceback, which carry no level name
+	crashPrefixes = map[string]Level{
+		"panic: ":                            LEVEL_FATAL,
+		"fatal error: ":                      LEVEL_FATAL,
+		"Traceback (most recent call last):": LEVEL_ERROR,
+	}
+)
+
+// ParseLevel returns the level with the given name, reporting false if there
+// is none.
+func ParseLevel(name string) (Level, bool) {
+	level, ok := levelNames[strings.ToLower(name)]
+	return level, ok
+}
+
+// DetectLevel returns the level of a line, from a level field of structured
+// logs or else the first upper case or bracketed level name. Colours and other
+// ANSI escape sequences are ignored.
+func DetectLevel(line string) Level {
+	line = StripANSI(line)
+
+	for prefix, level := range crashPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return level
+		}
+	}
+
+	if match := structuredLevelPattern.FindStringSubmatch(line); match != nil {
+		if level, ok := ParseLevel(match[1]); ok {
+			return level
+		}
+	}
+
+	if match := wordLevelPattern.FindStringSubmatch(line); match != nil {
+		name := match[1]
+		if name == "" {
+			name = match[2]
+		}
+		if level, ok := ParseLevel(name); ok {
+			return level
+		}
+	}
+
+	return LEVEL_UNKNOWN
+}
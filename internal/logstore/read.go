@@ -0,0 +1,106 @@
+package logstore
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ScanForward calls fn with the entries of a log from the oldest to the newest,
+// through its rotated segments, until fn returns false. Reading starts with the
+// newest file whose first entry is at or before both the sequence number after
+// and the time since, a zero value matches any entry. Earlier files cannot hold
+// entries following them and are skipped.
+func ScanForward(path string, after uint64, since time.Time, fn func(entry Entry) bool) error {
+	files, err := Files(path)
+	if err != nil {
+		return err
+	}
+
+	start := 0
+	if after != 0 || !since.IsZero() {
+		for i := len(files) - 1; i >= 0; i-- {
+			first, ok, err := firstEntryInFile(files[i])
+			if os.IsNotExist(err) {
+				// Removed by retention in the meantime
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			if ok && (after == 0 || first.Seq <= after) && (since.IsZero() || !first.Time.After(since)) {
+				start = i
+				break
+			}
+		}
+	}
+
+	reader := openFiles(files[start:])
+	defer reader.Close()
+
+	stream := filepath.Base(path)
+
+	lineReader := bufio.NewReader(reader)
+	for {
+		line, err := lineReader.ReadBytes('\n')
+		// A last line without a line break is still being written
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			if !fn(ParseEntry(trimLineEnding(line), stream)) {
+				return nil
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ReadAfter returns the first limit entries of a log with a sequence number
+// greater than after, oldest first.
+func ReadAfter(path string, after uint64, limit int) ([]Entry, error) {
+	var entries []Entry
+
+	err := ScanForward(path, after, time.Time{}, func(entry Entry) bool {
+		if entry.Seq > after {
+			entries = append(entries, entry)
+		}
+		return len(entries) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// firstEntryInFile returns the first entry of a file, reporting false if it has
+// none.
+func firstEntryInFile(path string) (Entry, bool, error) {
+	file, err := OpenSegment(path)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if entry, ok := decodeEntry(trimLineEnding(line)); ok {
+			return entry, true, nil
+		}
+
+		if err == io.EOF {
+			return Entry{}, false, nil
+		}
+		if err != nil {
+			return Entry{}, false, err
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package logstore
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	// Serialize the compression and retention of the segments of a log
+	// directory. Every run of a service opens new files for its streams, so
+	// the segments rotated by several files can be processed at once.
+	retentionMutexes      = make(map[string]*sync.Mutex)
+	retentionMutexesMutex sync.Mutex
+)
+
+// RotationPolicy decides when a log file is rotated and how many rotated
+// segments are kept. Zero values disable the corresponding limit.
+type RotationPolicy struct {
+	// MaxSizeBytes rotates the file once it grows past this size
+	MaxSizeBytes int64 `json:"max_size_bytes"`
+	// MaxAgeSeconds rotates the file once it is older than this
+	MaxAgeSeconds float64 `json:"max_age_seconds"`
+	// MaxFiles is the number of rotated segments kept
+	MaxFiles int `json:"max_files"`
+	// MaxTotalBytes is the byte budget of the rotated segments
+	MaxTotalBytes int64 `json:"max_total_bytes"`
+}
+
+func (p RotationPolicy) Validate() error {
+	if p.MaxSizeBytes < 0 || p.MaxAgeSeconds < 0 || p.MaxFiles < 0 || p.MaxTotalBytes < 0 {
+		return errors.New("log rotation limits cannot be negative")
+	}
+	return nil
+}
+
+// Merge returns the policy with every non-zero field of override applied.
+func (p RotationPolicy) Merge(override RotationPolicy) RotationPolicy {
+	if override.MaxSizeBytes != 0 {
+		p.MaxSizeBytes = override.MaxSizeBytes
+	}
+	if override.MaxAgeSeconds != 0 {
+		p.MaxAgeSeconds = override.MaxAgeSeconds
+	}
+	if override.MaxFiles != 0 {
+		p.MaxFiles = override.MaxFiles
+	}
+	if override.MaxTotalBytes != 0 {
+		p.MaxTotalBytes = override.MaxTotalBytes
+	}
+	return p
+}
+
+func (p RotationPolicy) maxAge() time.Duration {
+	return time.Duration(p.MaxAgeSeconds * float64(time.Second))
+}
+
+// shouldRotate reports whether a file of the given size and creation time has to
+// be rotated before pendingBytes are written to it.
+func (p RotationPolicy) shouldRotate(size int64, createdAt time.Time, pendingBytes int) bool {
+	if size == 0 {
+		return false
+	}
+
+	if p.MaxSizeBytes > 0 && size+int64(pendingBytes) > p.MaxSizeBytes {
+		return true
+	}
+
+	if p.MaxAgeSeconds > 0 && time.Since(createdAt) > p.maxAge() {
+		return true
+	}
+
+	return false
+}
+
+// compressSegment gzips a rotated segment and removes the uncompressed copy.
+func compressSegment(segmentPath string) error {
+	source, err := os.Open(segmentPath)
+	if err != nil {
+		return fmt.Errorf("open segment: %w", err)
+	}
+	defer source.Close()
+
+	// Written under a temporary name, readers must not see a partial segment
+	compressedPath := segmentPath + COMPRESSED_EXTENSION
+	temporaryPath := compressedPath + ".tmp"
+	target, err := os.OpenFile(temporaryPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("create compressed segment: %w", err)
+	}
+
+	gzipWriter := gzip.NewWriter(target)
+
+	_, err = io.Copy(gzipWriter, source)
+	if err == nil {
+		err = gzipWriter.Close()
+	}
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(temporaryPath, compressedPath)
+	}
+
+	if err != nil {
+		os.Remove(temporaryPath)
+		return fmt.Errorf("compress segment: %w", err)
+	}
+
+	return os.Remove(segmentPath)
+}
+
+// directoryRetentionMutex returns the mutex serializing the compression and
+// retention of the segments in a log directory.
+func directoryRetentionMutex(dir string) *sync.Mutex {
+	retentionMutexesMutex.Lock()
+	defer retentionMutexesMutex.Unlock()
+
+	dir = filepath.Clean(dir)
+	mutex, ok := retentionMutexes[dir]
+	if !ok {
+		mutex = &sync.Mutex{}
+		retentionMutexes[dir] = mutex
+	}
+
+	return mutex
+}
+
+// applyRetention removes the oldest rotated segments of the log file until the
+// policy limits are satisfied.
+func applyRetention(path string, policy RotationPolicy) error {
+	if policy.MaxFiles == 0 && policy.MaxTotalBytes == 0 {
+		return nil
+	}
+
+	segments, err := Segments(path)
+	if err != nil {
+		return err
+	}
+
+	sizes := make([]int64, len(segments))
+	var totalBytes int64
+	for i, segment := range segments {
+		if info, err := os.Stat(segment); err == nil {
+			sizes[i] = info.Size()
+			totalBytes += sizes[i]
+		}
+	}
+
+	for i, segment := range segments {
+		remaining := len(segments) - i
+
+		overCount := policy.MaxFiles > 0 && remaining > policy.MaxFiles
+		overBudget := policy.MaxTotalBytes > 0 && totalBytes > policy.MaxTotalBytes
+		if !overCount && !overBudget {
+			break
+		}
+
+		if err := os.Remove(segment); err != nil && !os.IsNotExist(err) {
+			log.Printf("failed to remove log segment %s: %v", segment, err)
+			continue
+		}
+		totalBytes -= sizes[i]
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package logstore
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Size of the blocks read when scanning a file backwards
+const BACKWARD_BLOCK_SIZE = 64 * 1024
+
+// ScanBackward calls fn with the entries of a log from the newest to the
+// oldest, through its rotated segments, until fn returns false. Compressed
+// segments are decompressed to a temporary file to be read backwards.
+func ScanBackward(path string, fn func(entry Entry) bool) error {
+	files, err := Files(path)
+	if err != nil {
+		return err
+	}
+
+	stream := filepath.Base(path)
+
+	for i := len(files) - 1; i >= 0; i-- {
+		more, err := scanFileBackward(files[i], stream, fn)
+		if os.IsNotExist(err) {
+			// Removed by retention in the meantime
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if !more {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func scanFileBackward(path string, stream string, fn func(entry Entry) bool) (bool, error) {
+	file, err := openSeekable(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	more := true
+	err = scanBackward(file, info.Size(), func(line []byte) bool {
+		more = fn(ParseEntry(trimLineEnding(line), stream))
+		return more
+	})
+
+	return more, err
+}
+
+// seekableFile is a log file that can be read at any offset, compressed
+// segments are decompressed to a temporary file removed on Close.
+type seekableFile struct {
+	*os.File
+	temporary bool
+}
+
+func (f *seekableFile) Close() error {
+	err := f.File.Close()
+	if f.temporary {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func openSeekable(path string) (*seekableFile, error) {
+	if !strings.HasSuffix(path, COMPRESSED_EXTENSION) {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		return &seekableFile{File: file}, nil
+	}
+
+	segment, err := OpenSegment(path)
+	if err != nil {
+		return nil, err
+	}
+	defer segment.Close()
+
+	file, err := os.CreateTemp("", "service-manager-segment-*")
+	if err != nil {
+		return nil, fmt.Errorf("create temporary file: %w", err)
+	}
+	decompressed := &seekableFile{File: file, temporary: true}
+
+	if _, err := io.Copy(file, segment); err != nil {
+		decompressed.Close()
+		return nil, fmt.Errorf("decompress segment %s: %w", path, err)
+	}
+
+	return decompressed, nil
+}
+
+// scanBackward calls fn with the non-empty lines of a file from the last to the
+// first, until fn returns false. The line is only valid during the call. A last
+// line without a line break is still being written and is skipped.
+func scanBackward(file io.ReaderAt, size int64, fn func(line []byte) bool) error {
+	block := make([]byte, BACKWARD_BLOCK_SIZE)
+	// Start of a line whose beginning is in a block not read yet
+	var pending []byte
+	offset := size
+	partial := false
+
+	for offset > 0 {
+		n := min(int64(BACKWARD_BLOCK_SIZE), offset)
+		offset -= n
+
+		if _, err := file.ReadAt(block[:n], offset); err != nil {
+			return err
+		}
+
+		if offset+n == size {
+			partial = block[n-1] != '\n'
+		}
+
+		chunk := append(block[:n:n], pending...)
+
+		for {
+			i := bytes.LastIndexByte(chunk, '\n')
+			if i < 0 {
+				break
+			}
+
+			line := chunk[i+1:]
+			chunk = chunk[:i]
+
+			if partial {
+				partial = false
+				continue
+			}
+
+			if len(line) > 0 && !fn(line) {
+				return nil
+			}
+		}
+
+		pending = append(pending[:0], chunk...)
+	}
+
+	if len(pending) > 0 && !partial {
+		fn(pending)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package logstore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeLog writes a log of the given number of entries, rotated every
+// segmentEntries entries, the remaining ones being in the active file. The
+// rotated segments are compressed unless uncompressed is set. The lines are
+// long enough for a segment to span several blocks.
+func writeLog(t *testing.T, entries, segmentEntries int, uncompressed bool) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "stdout")
+	rotatedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var content []byte
+	for seq := 1; seq <= entries; seq++ {
+		line := fmt.Sprintf("line %d %s", seq, strings.Repeat("x", 1000))
+		content = append(content, Entry{Seq: uint64(seq), Time: rotatedAt, Stream: "stdout", Line: line}.Encode()...)
+
+		if seq%segmentEntries != 0 {
+			continue
+		}
+
+		segment := segmentPath(path, rotatedAt.Add(time.Duration(seq)*time.Second))
+		if err := os.WriteFile(segment, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if !uncompressed {
+			if err := compressSegment(segment); err != nil {
+				t.Fatal(err)
+			}
+		}
+		content = nil
+	}
+
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestScanBackward(t *testing.T) {
+	tests := []struct {
+		name           string
+		entries        int
+		segmentEntries int
+		uncompressed   bool
+		// Number of entries read before stopping, 0 reads them all
+		stopAfter int
+	}{
+		{name: "active file only", entries: 200, segmentEntries: 1000},
+		{name: "compressed segments", entries: 500, segmentEntries: 120},
+		{name: "uncompressed segments", entries: 500, segmentEntries: 120, uncompressed: true},
+		{name: "stop in the active file", entries: 500, segmentEntries: 120, stopAfter: 10},
+		{name: "stop in a compressed segment", entries: 500, segmentEntries: 120, stopAfter: 300},
+		{name: "empty active file", entries: 240, segmentEntries: 120},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeLog(t, test.entries, test.segmentEntries, test.uncompressed)
+
+			var seqs []uint64
+			err := ScanBackward(path, func(entry Entry) bool {
+				seqs = append(seqs, entry.Seq)
+				return len(seqs) != test.stopAfter
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := test.entries
+			if test.stopAfter != 0 {
+				want = test.stopAfter
+			}
+			if len(seqs) != want {
+				t.Fatalf("read %d entries, want %d", len(seqs), want)
+			}
+			for i, seq := range seqs {
+				if seq != uint64(test.entries-i) {
+					t.Fatalf("entry %d is %d, want %d", i, seq, test.entries-i)
+				}
+			}
+		})
+	}
+}
+
+func TestScanBackwardSkipsPartialLine(t *testing.T) {
+	path := writeLog(t, 3, 1000, false)
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A line still being written, without its line break
+	file.WriteString(`{"seq":4,"time":"2025-01-01T00:00:00Z","stream":"stdout","line":"partial`)
+	file.Close()
+
+	var seqs []uint64
+	if err := ScanBackward(path, func(entry Entry) bool {
+		seqs = append(seqs, entry.Seq)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(seqs) != "[3 2 1]" {
+		t.Fatalf("read %v, want [3 2 1]", seqs)
+	}
+}
+
+func TestScanBackwardWithoutLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "stdout")
+
+	err := ScanBackward(path, func(entry Entry) bool {
+		t.Fatalf("read %+v from a log that does not exist", entry)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,125 @@
+package logstore
+
+import (
+	"slices"
+	"time"
+)
+
+// Query selects entries of the logs of a service. Lines logged before entries
+// were introduced have no sequence number and are never selected.
+type Query struct {
+	// Captured at or after From and before To, a zero time is not a bound
+	From time.Time
+	To   time.Time
+	// Reports whether the line matches, nil matches every line
+	Match func(line string) bool
+	// Forward returns the oldest matching entries after the sequence number
+	// After, otherwise the newest ones before Before are returned, where 0
+	// means the end of the logs.
+	Forward bool
+	After   uint64
+	Before  uint64
+	Limit   int
+}
+
+// Page is a page of entries found by Search, oldest first.
+type Page struct {
+	Entries []Entry
+	// Whether more entries match in the direction of the search
+	More bool
+}
+
+func (q Query) matches(entry Entry) bool {
+	if entry.Seq == 0 {
+		return false
+	}
+	if !q.From.IsZero() && entry.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !entry.Time.Before(q.To) {
+		return false
+	}
+	return q.Match == nil || q.Match(entry.Line)
+}
+
+// Search returns a page of the entries of logs matching a query, merged in
+// capture order. The logs are read entry by entry, through their rotated
+// segments, until the page is full.
+func Search(paths []string, query Query) (Page, error) {
+	var found [][]Entry
+
+	for _, path := range paths {
+		var entries []Entry
+		var err error
+
+		if query.Forward {
+			entries, err = searchForward(path, query)
+		} else {
+			entries, err = searchBackward(path, query)
+		}
+		if err != nil {
+			return Page{}, err
+		}
+
+		found = append(found, entries)
+	}
+
+	page := Page{Entries: MergeEntries(found...)}
+
+	// Every log contributed up to one entry more than the limit, which tells
+	// whether there is another page
+	if len(page.Entries) > query.Limit {
+		page.More = true
+		if query.Forward {
+			page.Entries = page.Entries[:query.Limit]
+		} else {
+			page.Entries = page.Entries[len(page.Entries)-query.Limit:]
+		}
+	}
+
+	return page, nil
+}
+
+func searchForward(path string, query Query) ([]Entry, error) {
+	var entries []Entry
+
+	err := ScanForward(path, query.After, query.From, func(entry Entry) bool {
+		if entry.Seq <= query.After {
+			return true
+		}
+		if !query.To.IsZero() && !entry.Time.Before(query.To) {
+			return false
+		}
+
+		if query.matches(entry) {
+			entries = append(entries, entry)
+		}
+
+		return len(entries) <= query.Limit
+	})
+
+	return entries, err
+}
+
+func searchBackward(path string, query Query) ([]Entry, error) {
+	var entries []Entry
+
+	err := ScanBackward(path, func(entry Entry) bool {
+		if query.Before != 0 && entry.Seq >= query.Before {
+			return true
+		}
+		if !query.From.IsZero() && entry.Time.Before(query.From) {
+			return false
+		}
+
+		if query.matches(entry) {
+			entries = append(entries, entry)
+		}
+
+		return len(entries) <= query.Limit
+	})
+
+	slices.Reverse(entries)
+
+	return entries, err
+}
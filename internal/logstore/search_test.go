@@ -0,0 +1,175 @@
+package logstore
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeStreams writes the logs of a service whose entries alternate between
+// stdout and stderr, returning their paths. Entry n is captured n seconds
+// after start and its line is "even" or "odd".
+func writeStreams(t *testing.T, entries int, start time.Time) []string {
+	t.Helper()
+
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "stdout"), filepath.Join(dir, "stderr")}
+	contents := make([][]byte, 2)
+
+	for seq := 1; seq <= entries; seq++ {
+		line := "odd"
+		if seq%2 == 0 {
+			line = "even"
+		}
+
+		stream := seq % 2
+		entry := Entry{
+			Seq:    uint64(seq),
+			Time:   start.Add(time.Duration(seq) * time.Second),
+			Stream: filepath.Base(paths[stream]),
+			Line:   line,
+		}
+		contents[stream] = append(contents[stream], entry.Encode()...)
+	}
+
+	for i, path := range paths {
+		if err := os.WriteFile(path, contents[i], 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return paths
+}
+
+func pageSeqs(page Page) []uint64 {
+	seqs := make([]uint64, 0, len(page.Entries))
+	for _, entry := range page.Entries {
+		seqs = append(seqs, entry.Seq)
+	}
+	return seqs
+}
+
+func TestSearch(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	even := func(line string) bool { return strings.Contains(line, "even") }
+
+	tests := []struct {
+		name     string
+		query    Query
+		want     []uint64
+		wantMore bool
+	}{
+		{
+			name:     "newest",
+			query:    Query{Limit: 3},
+			want:     []uint64{8, 9, 10},
+			wantMore: true,
+		},
+		{
+			name:     "before a cursor",
+			query:    Query{Before: 4, Limit: 3},
+			want:     []uint64{1, 2, 3},
+			wantMore: false,
+		},
+		{
+			name:     "oldest",
+			query:    Query{Forward: true, Limit: 3},
+			want:     []uint64{1, 2, 3},
+			wantMore: true,
+		},
+		{
+			name:     "after a cursor",
+			query:    Query{Forward: true, After: 7, Limit: 3},
+			want:     []uint64{8, 9, 10},
+			wantMore: false,
+		},
+		{
+			name:     "matching lines",
+			query:    Query{Match: even, Limit: 2},
+			want:     []uint64{8, 10},
+			wantMore: true,
+		},
+		{
+			name:     "time range",
+			query:    Query{From: start.Add(3 * time.Second), To: start.Add(6 * time.Second), Limit: 10},
+			want:     []uint64{3, 4, 5},
+			wantMore: false,
+		},
+		{
+			name:     "time range forward",
+			query:    Query{Forward: true, From: start.Add(3 * time.Second), To: start.Add(6 * time.Second), Limit: 2},
+			want:     []uint64{3, 4},
+			wantMore: true,
+		},
+		{
+			name:  "nothing matching",
+			query: Query{Match: func(string) bool { return false }, Limit: 5},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page, err := Search(writeStreams(t, 10, start), test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := pageSeqs(page); !slices.Equal(got, test.want) {
+				t.Errorf("entries %v, want %v", got, test.want)
+			}
+			if page.More != test.wantMore {
+				t.Errorf("more is %v, want %v", page.More, test.wantMore)
+			}
+		})
+	}
+}
+
+// Following the cursors of the pages returns every entry once, in both
+// directions.
+func TestSearchPaging(t *testing.T) {
+	paths := writeStreams(t, 25, time.Now())
+
+	for _, limit := range []int{1, 4, 7, 25, 100} {
+		var backward []uint64
+		query := Query{Limit: limit}
+		for {
+			page, err := Search(paths, query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			backward = append(pageSeqs(page), backward...)
+
+			if !page.More {
+				break
+			}
+			query.Before = page.Entries[0].Seq
+		}
+
+		var forward []uint64
+		query = Query{Forward: true, Limit: limit}
+		for {
+			page, err := Search(paths, query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			forward = append(forward, pageSeqs(page)...)
+
+			if !page.More {
+				break
+			}
+			query.After = page.Entries[len(page.Entries)-1].Seq
+		}
+
+		for i := range 25 {
+			if len(backward) != 25 || backward[i] != uint64(i+1) {
+				t.Fatalf("paging backward by %d read %v", limit, backward)
+			}
+			if len(forward) != 25 || forward[i] != uint64(i+1) {
+				t.Fatalf("paging forward by %d read %v", limit, forward)
+			}
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package logstore
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	COMPRESSED_EXTENSION = ".gz"
+	// Fixed width so that segment names sort chronologically
+	SEGMENT_TIME_FORMAT = "20060102T150405.000000000"
+)
+
+// segmentPath returns the path a log file is renamed to when rotated at rotatedAt.
+func segmentPath(path string, rotatedAt time.Time) string {
+	return fmt.Sprintf("%s.%s", path, rotatedAt.UTC().Format(SEGMENT_TIME_FORMAT))
+}
+
+// Segments returns the rotated segments of a log file, oldest first.
+// The active file itself is not included.
+func Segments(path string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	prefix := filepath.Base(path) + "."
+	names := make(map[string]bool)
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		timestamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), COMPRESSED_EXTENSION)
+		if _, err := time.Parse(SEGMENT_TIME_FORMAT, timestamp); err != nil {
+			continue
+		}
+
+		names[name] = true
+	}
+
+	var segments []string
+	for name := range names {
+		// A segment is briefly present both compressed and uncompressed while
+		// it is being compressed, the uncompressed one is complete
+		if uncompressed, ok := strings.CutSuffix(name, COMPRESSED_EXTENSION); ok && names[uncompressed] {
+			continue
+		}
+
+		segments = append(segments, filepath.Join(filepath.Dir(path), name))
+	}
+
+	sort.Strings(segments)
+
+	return segments, nil
+}
+
+// Files returns the rotated segments followed by the active file, if it exists.
+func Files(path string) ([]string, error) {
+	files, err := Segments(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+
+	return files, nil
+}
+
+// OpenSegment opens a segment or the active file, decompressing it if needed.
+func OpenSegment(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(path, COMPRESSED_EXTENSION) {
+		return file, nil
+	}
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("open compressed segment %s: %w", path, err)
+	}
+
+	return &gzipFile{Reader: gzipReader, file: file}, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+// Open returns a reader over the whole log, from the oldest rotated segment to
+// the end of the active file. It returns an error satisfying os.IsNotExist when
+// nothing was logged yet.
+func Open(path string) (io.ReadCloser, error) {
+	files, err := Files(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+
+	return openFiles(files), nil
+}
+
+func openFiles(files []string) io.ReadCloser {
+	return &multiFileReader{files: files}
+}
+
+// multiFileReader reads files one after the other, opening them lazily so that
+// segments removed by retention in the meantime are skipped.
+type multiFileReader struct {
+	files   []string
+	current io.ReadCloser
+}
+
+func (m *multiFileReader) Read(p []byte) (int, error) {
+	for {
+		if m.current == nil {
+			if len(m.files) == 0 {
+				return 0, io.EOF
+			}
+
+			file, err := OpenSegment(m.files[0])
+			m.files = m.files[1:]
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return 0, err
+			}
+			m.current = file
+		}
+
+		n, err := m.current.Read(p)
+		if err == io.EOF {
+			m.current.Close()
+			m.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+
+		return n, err
+	}
+}
+
+func (m *multiFileReader) Close() error {
+	if m.current != nil {
+		return m.current.Close()
+	}
+	return nil
+}
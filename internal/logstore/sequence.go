@@ -0,0 +1,128 @@
+package logstore
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sequence numbers the entries of a service, shared by all of its streams so
+// that their lines can be ordered against each other.
+type Sequence struct {
+	last  uint64
+	mutex sync.Mutex
+}
+
+// NewSequence returns a sequence continuing after the last entry of the logs.
+func NewSequence(paths ...string) (*Sequence, error) {
+	sequence := &Sequence{}
+
+	for _, path := range paths {
+		last, err := LastSeq(path)
+		if err != nil {
+			return nil, err
+		}
+
+		sequence.last = max(sequence.last, last)
+	}
+
+	return sequence, nil
+}
+
+// Stamp returns a line captured now as the next entry of the sequence, the
+// first one is 1. The entry is passed to publish before the next one is
+// stamped, so entries are published in sequence order.
+func (s *Sequence) Stamp(stream string, line string, publish func(entry Entry)) Entry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.last++
+	entry := Entry{
+		Seq:    s.last,
+		Time:   time.Now(),
+		Stream: stream,
+		Line:   line,
+	}
+
+	publish(entry)
+
+	return entry
+}
+
+// LastSeq returns the sequence number of the last entry of a log, or 0 if it
+// has none.
+func LastSeq(path string) (uint64, error) {
+	files, err := Files(path)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := len(files) - 1; i >= 0; i-- {
+		last, err := lastSeqInFile(files[i])
+		if os.IsNotExist(err) {
+			// Removed by retention in the meantime
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if last > 0 {
+			return last, nil
+		}
+	}
+
+	return 0, nil
+}
+
+func lastSeqInFile(path string) (uint64, error) {
+	var last uint64
+
+	// Compressed segments cannot be read backwards, they are read to the end
+	if strings.HasSuffix(path, COMPRESSED_EXTENSION) {
+		file, err := OpenSegment(path)
+		if err != nil {
+			return 0, err
+		}
+		defer file.Close()
+
+		reader := bufio.NewReader(file)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if entry, ok := decodeEntry(trimLineEnding(line)); ok {
+				last = entry.Seq
+			}
+
+			if err == io.EOF {
+				return last, nil
+			}
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	err = scanBackward(file, info.Size(), func(line []byte) bool {
+		entry, ok := decodeEntry(trimLineEnding(line))
+		if ok {
+			last = entry.Seq
+		}
+		return !ok
+	})
+
+	return last, err
+}
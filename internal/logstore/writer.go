@@ -0,0 +1,134 @@
+package logstore
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// Number of entries a writer holds before dropping new ones
+	WRITER_QUEUE_SIZE = 4096
+	// How often buffered entries are flushed to disk
+	WRITER_FLUSH_INTERVAL = 500 * time.Millisecond
+)
+
+// Writer appends the entries of a service stream to its log file from a
+// background goroutine. Append never blocks: when the disk cannot keep up and
+// the queue is full, entries are dropped and a marker entry recording how many
+// were lost takes the place of the first one.
+type Writer struct {
+	file   *File
+	queue  chan Entry
+	closed bool
+	// Guards closed and the queue channel against Append after Close
+	mutex sync.RWMutex
+	done  chan struct{}
+
+	// First entry dropped since the last marker and the number dropped
+	droppedFrom  Entry
+	droppedCount int64
+	dropMutex    sync.Mutex
+}
+
+func NewWriter(path string, policy RotationPolicy) *Writer {
+	w := &Writer{
+		file:  NewFile(path, policy),
+		queue: make(chan Entry, WRITER_QUEUE_SIZE),
+		done:  make(chan struct{}),
+	}
+
+	go w.run()
+
+	return w
+}
+
+// Append queues an entry, it is dropped if the queue is full or the writer
+// closed. Entries must be appended in sequence order.
+func (w *Writer) Append(entry Entry) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	if w.closed {
+		return
+	}
+
+	select {
+	case w.queue <- entry:
+	default:
+		w.dropMutex.Lock()
+		if w.droppedCount == 0 {
+			w.droppedFrom = entry
+		}
+		w.droppedCount++
+		w.dropMutex.Unlock()
+	}
+}
+
+// Close writes the queued entries, flushes and closes the file.
+func (w *Writer) Close() error {
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mutex.Unlock()
+
+	<-w.done
+
+	return w.file.Close()
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(WRITER_FLUSH_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case entry, ok := <-w.queue:
+			if !ok {
+				w.writeDroppedMarker(0)
+				return
+			}
+			// Entries queued before the drop are written before its marker
+			w.writeDroppedMarker(entry.Seq)
+			w.write(entry)
+
+		case <-ticker.C:
+			if len(w.queue) == 0 {
+				w.writeDroppedMarker(0)
+			}
+			if err := w.file.Flush(); err != nil {
+				log.Printf("failed to flush log file %s: %v", w.file.Path(), err)
+			}
+		}
+	}
+}
+
+// writeDroppedMarker writes the marker of the dropped entries if it comes before
+// the entry with sequence number before, 0 writes it unconditionally.
+func (w *Writer) writeDroppedMarker(before uint64) {
+	w.dropMutex.Lock()
+	if w.droppedCount == 0 || (before != 0 && before < w.droppedFrom.Seq) {
+		w.dropMutex.Unlock()
+		return
+	}
+
+	marker := w.droppedFrom
+	marker.Line = fmt.Sprintf("[service-manager] %d lines dropped, the log writer could not keep up", w.droppedCount)
+	w.droppedCount = 0
+	w.dropMutex.Unlock()
+
+	w.write(marker)
+}
+
+func (w *Writer) write(entry Entry) {
+	if _, err := w.file.Write(entry.Encode()); err != nil {
+		log.Printf("failed to write to file %s: %v", w.file.Path(), err)
+	}
+}
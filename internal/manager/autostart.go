@@ -0,0 +1,121 @@
+package manager
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// autostartOrder returns the services flagged for autostart, every service
+// coming after the autostart services it depends on.
+func autostartOrder(services map[string]*service) []*service {
+	var flagged []*service
+	for _, service := range services {
+		if service.Config.Autostart {
+			flagged = append(flagged, service)
+		}
+	}
+
+	// Keep the order stable between boots
+	sort.Slice(flagged, func(i, j int) bool {
+		return flagged[i].Name < flagged[j].Name
+	})
+
+	var order []*service
+	seen := make(map[string]bool)
+
+	for _, flaggedService := range flagged {
+		dependencies, err := dependencyOrder(services, flaggedService.ID)
+		if err != nil {
+			dependencies = []*service{flaggedService}
+		}
+
+		for _, dependency := range dependencies {
+			if !dependency.Config.Autostart || seen[dependency.ID] {
+				continue
+			}
+			seen[dependency.ID] = true
+			order = append(order, dependency)
+		}
+	}
+
+	return order
+}
+
+// AutostartServices starts every service flagged for autostart in dependency
+// order, waiting stagger between two services. The progress can be followed
+// with GetStartupSummary.
+func (sm *ServiceManager) AutostartServices(stagger time.Duration) StartupSummary {
+	sm.readWriteMutex.RLock()
+	order := autostartOrder(sm.services)
+	sm.readWriteMutex.RUnlock()
+
+	sm.summaryMutex.Lock()
+	sm.startupSummary = StartupSummary{
+		StartedAt: time.Now(),
+		Results:   []AutostartResult{},
+	}
+	sm.summaryMutex.Unlock()
+
+	for i, service := range order {
+		if i > 0 && stagger > 0 {
+			time.Sleep(stagger)
+		}
+
+		result := AutostartResult{
+			ServiceID: service.ID,
+			Name:      service.Name,
+			Started:   true,
+		}
+
+		// Already brought up as the dependency of another service
+		if service.GetStatus() == SERVICE_STOPPED {
+			if err := sm.StartService(service.ID); err != nil {
+				result.Started = false
+				result.Error = err.Error()
+			}
+		}
+
+		sm.summaryMutex.Lock()
+		sm.startupSummary.Results = append(sm.startupSummary.Results, result)
+		sm.summaryMutex.Unlock()
+	}
+
+	sm.summaryMutex.Lock()
+	sm.startupSummary.FinishedAt = time.Now()
+	summary := sm.startupSummary
+	sm.summaryMutex.Unlock()
+
+	logStartupSummary(summary)
+
+	return summary
+}
+
+func (sm *ServiceManager) GetStartupSummary() StartupSummary {
+	sm.summaryMutex.Lock()
+	defer sm.summaryMutex.Unlock()
+
+	summary := sm.startupSummary
+	summary.Results = append([]AutostartResult{}, summary.Results...)
+
+	return summary
+}
+
+func logStartupSummary(summary StartupSummary) {
+	var started, failed []string
+	for _, result := range summary.Results {
+		if result.Started {
+			started = append(started, result.Name)
+		} else {
+			failed = append(failed, result.Name)
+			log.Printf("autostart: service '%s' (ID: '%s') failed to start: %s", result.Name, result.ServiceID, result.Error)
+		}
+	}
+
+	log.Printf(
+		"autostart: %d service(s) started [%s], %d failed [%s]",
+		len(started), strings.Join(started, ", "),
+		len(failed), strings.Join(failed, ", "),
+	)
+}
@@ -0,0 +1,267 @@
+//go:build linux
+
+package manager
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Period of the CPU quota, in microseconds
+	CGROUP_CPU_PERIOD = 100000
+	// How long removing the cgroup of a run is retried while its processes exit
+	CGROUP_REMOVE_TIMEOUT = 2 * time.Second
+)
+
+func (limits ResourceLimits) validate() error {
+	if limits.MemoryMaxBytes < 0 || limits.MemoryHighBytes < 0 || limits.CPUQuota < 0 || limits.PidsMax < 0 {
+		return errors.New("resource limits cannot be negative")
+	}
+
+	if limits.MemoryMaxBytes != 0 && limits.MemoryHighBytes > limits.MemoryMaxBytes {
+		return errors.New("memory high cannot be above memory max")
+	}
+
+	if limits.CPUQuota != 0 && limits.CPUQuota*CGROUP_CPU_PERIOD < 1000 {
+		return errors.New("cpu quota must be at least 0.01")
+	}
+
+	if limits.CPUWeight != 0 && (limits.CPUWeight < 1 || limits.CPUWeight > 10000) {
+		return errors.New("cpu weight must be between 1 and 10000")
+	}
+
+	if limits.IOWeight != 0 && (limits.IOWeight < 1 || limits.IOWeight > 10000) {
+		return errors.New("io weight must be between 1 and 10000")
+	}
+
+	return nil
+}
+
+// controllers returns the cgroup controllers enforcing the limits.
+func (limits ResourceLimits) controllers() []string {
+	var controllers []string
+
+	if limits.MemoryMaxBytes != 0 || limits.MemoryHighBytes != 0 {
+		controllers = append(controllers, "memory")
+	}
+	if limits.CPUQuota != 0 || limits.CPUWeight != 0 {
+		controllers = append(controllers, "cpu")
+	}
+	if limits.PidsMax != 0 {
+		controllers = append(controllers, "pids")
+	}
+	if limits.IOWeight != 0 {
+		controllers = append(controllers, "io")
+	}
+
+	return controllers
+}
+
+// settings returns the values written to the interface files of the cgroup.
+func (limits ResourceLimits) settings() map[string]string {
+	settings := make(map[string]string)
+
+	if limits.MemoryMaxBytes != 0 {
+		settings["memory.max"] = strconv.FormatInt(limits.MemoryMaxBytes, 10)
+	}
+	if limits.MemoryHighBytes != 0 {
+		settings["memory.high"] = strconv.FormatInt(limits.MemoryHighBytes, 10)
+	}
+	if limits.CPUQuota != 0 {
+		quota := int64(limits.CPUQuota * CGROUP_CPU_PERIOD)
+		settings["cpu.max"] = fmt.Sprintf("%d %d", quota, CGROUP_CPU_PERIOD)
+	}
+	if limits.CPUWeight != 0 {
+		settings["cpu.weight"] = strconv.Itoa(limits.CPUWeight)
+	}
+	if limits.PidsMax != 0 {
+		settings["pids.max"] = strconv.Itoa(limits.PidsMax)
+	}
+	if limits.IOWeight != 0 {
+		settings["io.weight"] = fmt.Sprintf("default %d", limits.IOWeight)
+	}
+
+	return settings
+}
+
+// serviceCgroup is the cgroup v2 a run of a service is placed in. The process
+// is started in it, so every process it forks is limited too.
+type serviceCgroup struct {
+	path string
+	dir  *os.File
+}
+
+// newServiceCgroup creates the cgroup of a run of a service in the manager
+// slice, relative to the cgroup v2 mount, and applies the limits.
+func newServiceCgroup(slice string, serviceID string, limits ResourceLimits) (*serviceCgroup, error) {
+	mount, err := cgroup2Mount()
+	if err != nil {
+		return nil, err
+	}
+
+	slicePath := filepath.Join(mount, slice)
+	if err := enableControllers(mount, slicePath, limits.controllers()); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(slicePath, serviceID)
+
+	// Left behind if the manager did not exit cleanly, it is empty by now
+	// unless processes of the previous run survived
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("remove cgroup of previous run: %w", err)
+	}
+
+	if err := os.Mkdir(path, 0755); err != nil {
+		return nil, fmt.Errorf("create cgroup: %w", err)
+	}
+
+	cgroup := &serviceCgroup{path: path}
+
+	for file, value := range limits.settings() {
+		if err := os.WriteFile(filepath.Join(path, file), []byte(value), 0); err != nil {
+			cgroup.remove()
+			return nil, fmt.Errorf("set %s: %w", file, err)
+		}
+	}
+
+	cgroup.dir, err = os.Open(path)
+	if err != nil {
+		cgroup.remove()
+		return nil, fmt.Errorf("open cgroup: %w", err)
+	}
+
+	return cgroup, nil
+}
+
+// fd returns the file descriptor the process is started in the cgroup with.
+func (c *serviceCgroup) fd() int {
+	return int(c.dir.Fd())
+}
+
+// oomKilled reports whether a process of the cgroup was killed for going over
+// its memory limit.
+func (c *serviceCgroup) oomKilled() bool {
+	file, err := os.Open(filepath.Join(c.path, "memory.events"))
+	if err != nil {
+		// Only present when the memory controller is enabled
+		return false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name, value, _ := strings.Cut(scanner.Text(), " ")
+		if name == "oom_kill" {
+			count, _ := strconv.ParseUint(value, 10, 64)
+			return count > 0
+		}
+	}
+
+	return false
+}
+
+// remove kills the processes left in the cgroup and removes it, waiting for
+// them to exit.
+func (c *serviceCgroup) remove() {
+	if c.dir != nil {
+		c.dir.Close()
+	}
+
+	// Not available before Linux 5.14, the process group is killed anyway
+	os.WriteFile(filepath.Join(c.path, "cgroup.kill"), []byte("1"), 0)
+
+	deadline := time.Now().Add(CGROUP_REMOVE_TIMEOUT)
+	for {
+		err := os.Remove(c.path)
+		if err == nil || os.IsNotExist(err) {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			log.Printf("could not remove cgroup %s: %v", c.path, err)
+			return
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// cgroup2Mount returns where the cgroup v2 hierarchy is mounted.
+func cgroup2Mount() (string, error) {
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", fmt.Errorf("read mounts: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// The filesystem type follows the " - " separator, the mount point is
+		// the fifth field
+		fields := strings.Fields(scanner.Text())
+		separator := slices.Index(fields, "-")
+		if separator < 0 || separator+1 >= len(fields) || len(fields) < 5 {
+			continue
+		}
+
+		if fields[separator+1] == "cgroup2" {
+			return fields[4], nil
+		}
+	}
+
+	return "", errors.New("cgroup v2 is not mounted")
+}
+
+// enableControllers makes the controllers available to the cgroups created in
+// the slice, creating the slice if needed. Controllers are enabled from the
+// mount down, each cgroup must get them from its parent.
+func enableControllers(mount, slicePath string, controllers []string) error {
+	relative, err := filepath.Rel(mount, slicePath)
+	if err != nil || strings.HasPrefix(relative, "..") {
+		return fmt.Errorf("cgroup slice '%s' is outside of the cgroup v2 mount", slicePath)
+	}
+
+	path := mount
+	for _, name := range append([]string{""}, strings.Split(relative, string(filepath.Separator))...) {
+		if name != "" && name != "." {
+			path = filepath.Join(path, name)
+			if err := os.Mkdir(path, 0755); err != nil && !os.IsExist(err) {
+				return fmt.Errorf("create cgroup slice: %w", err)
+			}
+		}
+
+		available, err := os.ReadFile(filepath.Join(path, "cgroup.controllers"))
+		if err != nil {
+			return fmt.Errorf("read cgroup controllers: %w", err)
+		}
+
+		var enable []string
+		for _, controller := range controllers {
+			if !slices.Contains(strings.Fields(string(available)), controller) {
+				return fmt.Errorf("cgroup controller '%s' is not available in %s", controller, path)
+			}
+			enable = append(enable, "+"+controller)
+		}
+
+		if len(enable) == 0 {
+			continue
+		}
+
+		err = os.WriteFile(filepath.Join(path, "cgroup.subtree_control"), []byte(strings.Join(enable, " ")), 0)
+		if err != nil {
+			return fmt.Errorf("enable cgroup controllers in %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
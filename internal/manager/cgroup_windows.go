@@ -0,0 +1,13 @@
+//go:build windows
+
+package manager
+
+import "errors"
+
+func (limits ResourceLimits) validate() error {
+	if limits != (ResourceLimits{}) {
+		return errors.New("resource limits are only supported on Linux")
+	}
+
+	return nil
+}
@@ -0,0 +1,238 @@
+//go:build linux
+
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+func validateRunAs(config ServiceConfig) error {
+	_, err := config.umask()
+	return err
+}
+
+// checkRunAs checks that the user and groups of the service exist and that
+// the manager can switch to them.
+func checkRunAs(config ServiceConfig) error {
+	_, err := config.credential()
+	return err
+}
+
+// credential returns the identity the process of the service is started
+// with, nil when it runs as the manager.
+func (config ServiceConfig) credential() (*syscall.Credential, error) {
+	if config.RunAsUser == "" && config.RunAsGroup == "" && len(config.SupplementaryGroups) == 0 {
+		return nil, nil
+	}
+
+	credential := &syscall.Credential{
+		Uid: uint32(os.Getuid()),
+		Gid: uint32(os.Getgid()),
+	}
+
+	var runAsUser *user.User
+	if config.RunAsUser != "" {
+		var err error
+		runAsUser, err = lookupUser(config.RunAsUser)
+		if err != nil {
+			return nil, err
+		}
+
+		credential.Uid, credential.Gid, err = userIDs(runAsUser)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.RunAsGroup != "" {
+		gid, err := lookupGroupID(config.RunAsGroup)
+		if err != nil {
+			return nil, err
+		}
+		credential.Gid = gid
+	}
+
+	var err error
+	if len(config.SupplementaryGroups) > 0 {
+		credential.Groups, err = groupIDs(config.SupplementaryGroups)
+	} else {
+		// Like su and sudo, the service keeps the groups of its user
+		credential.Groups, err = memberGroupIDs(runAsUser)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if os.Geteuid() == 0 {
+		return credential, nil
+	}
+
+	// Without root only the identity of the manager can be used, and the
+	// supplementary groups cannot be changed
+	if credential.Uid != uint32(os.Geteuid()) {
+		return nil, fmt.Errorf("running as user '%s' requires the manager to run as root", config.RunAsUser)
+	}
+	if credential.Gid != uint32(os.Getegid()) {
+		return nil, fmt.Errorf("running as group '%s' requires the manager to run as root", config.RunAsGroup)
+	}
+	if len(config.SupplementaryGroups) > 0 {
+		return nil, errors.New("setting supplementary groups requires the manager to run as root")
+	}
+
+	return nil, nil
+}
+
+// runAsService makes cmd run as the user and with the umask of the service,
+// like the command of the service.
+func (config ServiceConfig) runAsService(cmd *exec.Cmd) error {
+	credential, err := config.credential()
+	if err != nil {
+		return fmt.Errorf("resolve user: %w", err)
+	}
+
+	// Only the launcher can set the umask of the command
+	if umask, _ := config.umask(); umask != nil {
+		return useLauncher(cmd, launchSpec{Umask: umask, Credential: credential})
+	}
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: credential}
+	return nil
+}
+
+// umask returns the file mode creation mask of the service, nil when it is
+// inherited from the manager.
+func (config ServiceConfig) umask() (*uint32, error) {
+	if config.Umask == "" {
+		return nil, nil
+	}
+
+	mask, err := strconv.ParseUint(config.Umask, 8, 32)
+	if err != nil || mask > 0777 {
+		return nil, fmt.Errorf("umask '%s' is not an octal mode", config.Umask)
+	}
+
+	umask := uint32(mask)
+	return &umask, nil
+}
+
+// identityEnv returns the variables describing the user the service runs as,
+// which the environment of the service can override.
+func (config ServiceConfig) identityEnv() []string {
+	if config.RunAsUser == "" {
+		return nil
+	}
+
+	runAsUser, err := lookupUser(config.RunAsUser)
+	if err != nil {
+		return nil
+	}
+
+	return []string{
+		"USER=" + runAsUser.Username,
+		"LOGNAME=" + runAsUser.Username,
+		"HOME=" + runAsUser.HomeDir,
+	}
+}
+
+// lookupUser finds a user by name, or by id if name is numeric.
+func lookupUser(name string) (*user.User, error) {
+	found, err := user.Lookup(name)
+	if err != nil && isNumeric(name) {
+		found, err = user.LookupId(name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("user '%s' does not exist", name)
+	}
+
+	return found, nil
+}
+
+// lookupGroupID finds the id of a group by name, or by id if name is numeric.
+func lookupGroupID(name string) (uint32, error) {
+	group, err := user.LookupGroup(name)
+	if err != nil && isNumeric(name) {
+		group, err = user.LookupGroupId(name)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("group '%s' does not exist", name)
+	}
+
+	gid, err := strconv.ParseUint(group.Gid, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("group '%s' has an invalid id '%s'", name, group.Gid)
+	}
+
+	return uint32(gid), nil
+}
+
+// groupIDs looks up the ids of groups given by name or id.
+func groupIDs(names []string) ([]uint32, error) {
+	gids := make([]uint32, 0, len(names))
+	for _, name := range names {
+		gid, err := lookupGroupID(name)
+		if err != nil {
+			return nil, err
+		}
+		gids = append(gids, gid)
+	}
+
+	return gids, nil
+}
+
+// memberGroupIDs returns the ids of the groups a user is a member of, or the
+// supplementary groups of the manager when no user is given.
+func memberGroupIDs(member *user.User) ([]uint32, error) {
+	if member == nil {
+		groups, err := os.Getgroups()
+		if err != nil {
+			return nil, fmt.Errorf("read groups of the manager: %w", err)
+		}
+
+		gids := make([]uint32, len(groups))
+		for i, gid := range groups {
+			gids[i] = uint32(gid)
+		}
+		return gids, nil
+	}
+
+	groups, err := member.GroupIds()
+	if err != nil {
+		return nil, fmt.Errorf("read groups of user '%s': %w", member.Username, err)
+	}
+
+	gids := make([]uint32, 0, len(groups))
+	for _, group := range groups {
+		gid, err := strconv.ParseUint(group, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("user '%s' has an invalid group id '%s'", member.Username, group)
+		}
+		gids = append(gids, uint32(gid))
+	}
+
+	return gids, nil
+}
+
+func isNumeric(name string) bool {
+	_, err := strconv.ParseUint(name, 10, 32)
+	return err == nil
+}
+
+func userIDs(found *user.User) (uint32, uint32, error) {
+	uid, err := strconv.ParseUint(found.Uid, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("user '%s' has an invalid id '%s'", found.Username, found.Uid)
+	}
+
+	gid, err := strconv.ParseUint(found.Gid, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("user '%s' has an invalid group id '%s'", found.Username, found.Gid)
+	}
+
+	return uint32(uid), uint32(gid), nil
+}
@@ -0,0 +1,32 @@
+//go:build windows
+
+package manager
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func validateRunAs(config ServiceConfig) error {
+	if config.RunAsUser != "" || config.RunAsGroup != "" || len(config.SupplementaryGroups) > 0 {
+		return errors.New("running as another user is only supported on Linux")
+	}
+
+	if config.Umask != "" {
+		return errors.New("umask is only supported on Linux")
+	}
+
+	return nil
+}
+
+func checkRunAs(config ServiceConfig) error {
+	return nil
+}
+
+func (config ServiceConfig) runAsService(cmd *exec.Cmd) error {
+	return nil
+}
+
+func (config ServiceConfig) identityEnv() []string {
+	return nil
+}
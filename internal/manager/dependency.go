@@ -0,0 +1,258 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_DEPENDENCY_TIMEOUT = 60 * time.Second
+	DEPENDENCY_POLL_INTERVAL   = 200 * time.Millisecond
+)
+
+func (dependency Dependency) validate() error {
+	if dependency.ServiceID == "" {
+		return errors.New("dependency service id cannot be empty")
+	}
+
+	switch dependency.Condition {
+	case "", DEPENDENCY_STARTED, DEPENDENCY_HEALTHY, DEPENDENCY_LISTENING:
+	default:
+		return fmt.Errorf("unknown dependency condition '%s'", dependency.Condition)
+	}
+
+	if dependency.TimeoutSeconds < 0 {
+		return errors.New("dependency timeout cannot be negative")
+	}
+
+	return nil
+}
+
+func (dependency Dependency) timeout() time.Duration {
+	if dependency.TimeoutSeconds == 0 {
+		return DEFAULT_DEPENDENCY_TIMEOUT
+	}
+	return secondsToDuration(dependency.TimeoutSeconds)
+}
+
+// conditionMet reports whether the dependency service satisfies the condition.
+func (dependency Dependency) conditionMet(service *service) bool {
+	status := service.GetStatus()
+
+	switch dependency.Condition {
+	case DEPENDENCY_HEALTHY:
+		return status == SERVICE_HEALTHY
+	case DEPENDENCY_LISTENING:
+		if !status.IsActive() {
+			return false
+		}
+		for _, networkInfo := range service.GetNetworkInfo() {
+			if networkInfo.Port != 0 {
+				return true
+			}
+		}
+		return false
+	default:
+		return status.IsActive()
+	}
+}
+
+// waitForDependency polls the dependency service until its condition is met.
+func waitForDependency(dependency Dependency, service *service) error {
+	deadline := time.Now().Add(dependency.timeout())
+
+	for !dependency.conditionMet(service) {
+		if service.GetStatus() == SERVICE_STOPPED {
+			return fmt.Errorf("dependency '%s' (ID: '%s') stopped", service.Name, service.ID)
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf(
+				"dependency '%s' (ID: '%s') is not %s after %v",
+				service.Name, service.ID, dependency.conditionName(), dependency.timeout(),
+			)
+		}
+
+		time.Sleep(DEPENDENCY_POLL_INTERVAL)
+	}
+
+	return nil
+}
+
+func (dependency Dependency) conditionName() string {
+	if dependency.Condition == "" {
+		return string(DEPENDENCY_STARTED)
+	}
+	return string(dependency.Condition)
+}
+
+// validateDependencies checks that every dependency of the service exists, can
+// satisfy its condition and does not introduce a cycle.
+func validateDependencies(services map[string]*service, serviceID string) error {
+	for _, dependency := range services[serviceID].Config.DependsOn {
+		dependencyService, ok := services[dependency.ServiceID]
+		if !ok {
+			return fmt.Errorf("depends on unknown service '%s'", dependency.ServiceID)
+		}
+
+		if dependency.Condition == DEPENDENCY_HEALTHY && !dependencyService.Config.HealthCheck.enabled() {
+			return fmt.Errorf("depends on service '%s' being healthy but it has no health check", dependencyService.Name)
+		}
+	}
+
+	_, err := dependencyOrder(services, serviceID)
+	return err
+}
+
+// dependencyOrder returns the service and its transitive dependencies in
+// topological order: every service comes after the services it depends on.
+func dependencyOrder(services map[string]*service, serviceID string) ([]*service, error) {
+	var order []*service
+	done := make(map[string]bool)
+	visiting := make(map[string]bool)
+
+	var visit func(id string, path []string) error
+	visit = func(id string, path []string) error {
+		path = append(path, id)
+
+		if visiting[id] {
+			return fmt.Errorf("dependency cycle: %s", formatDependencyPath(services, path))
+		}
+		if done[id] {
+			return nil
+		}
+
+		service, ok := services[id]
+		if !ok {
+			return fmt.Errorf("service '%s' not found", id)
+		}
+
+		visiting[id] = true
+		for _, dependency := range service.Config.DependsOn {
+			if err := visit(dependency.ServiceID, path); err != nil {
+				return err
+			}
+		}
+		visiting[id] = false
+		done[id] = true
+
+		order = append(order, service)
+		return nil
+	}
+
+	if err := visit(serviceID, nil); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// dependentsOrder returns the services that depend on the service, directly or
+// not, in stop order: every service comes before the services it depends on.
+func dependentsOrder(services map[string]*service, serviceID string) []*service {
+	var order []*service
+	done := make(map[string]bool)
+
+	var visit func(id string)
+	visit = func(id string) {
+		if done[id] {
+			return
+		}
+		done[id] = true
+
+		for _, service := range services {
+			if service.dependsOn(id) {
+				visit(service.ID)
+			}
+		}
+
+		if id != serviceID {
+			order = append(order, services[id])
+		}
+	}
+
+	visit(serviceID)
+
+	return order
+}
+
+func (s *service) dependsOn(serviceID string) bool {
+	for _, dependency := range s.Config.DependsOn {
+		if dependency.ServiceID == serviceID {
+			return true
+		}
+	}
+	return false
+}
+
+func servicesByID(services []*service) map[string]*service {
+	byID := make(map[string]*service, len(services))
+	for _, service := range services {
+		byID[service.ID] = service
+	}
+	return byID
+}
+
+func formatDependencyPath(services map[string]*service, path []string) string {
+	names := make([]string, 0, len(path))
+	for _, id := range path {
+		if service, ok := services[id]; ok {
+			names = append(names, fmt.Sprintf("'%s'", service.Name))
+		} else {
+			names = append(names, fmt.Sprintf("'%s'", id))
+		}
+	}
+	return strings.Join(names, " -> ")
+}
+
+// stopInDependencyOrder stops the services in layers: a service is only stopped
+// once every service depending on it has been stopped. Services of a layer are
+// stopped concurrently.
+func stopInDependencyOrder(services map[string]*service) {
+	remaining := make(map[string]*service, len(services))
+	for id, service := range services {
+		remaining[id] = service
+	}
+
+	for len(remaining) > 0 {
+		var layer []*service
+		for id, service := range remaining {
+			hasDependents := false
+			for _, other := range remaining {
+				if other.dependsOn(id) {
+					hasDependents = true
+					break
+				}
+			}
+			if !hasDependents {
+				layer = append(layer, service)
+			}
+		}
+
+		// Cycles are rejected when services are registered, but never hang on one
+		if len(layer) == 0 {
+			for _, service := range remaining {
+				layer = append(layer, service)
+			}
+		}
+
+		var stopServiceWG sync.WaitGroup
+		for _, layerService := range layer {
+			delete(remaining, layerService.ID)
+
+			if layerService.GetStatus() == SERVICE_STOPPED {
+				continue
+			}
+
+			stopServiceWG.Add(1)
+			go func() {
+				defer stopServiceWG.Done()
+				layerService.Stop(nil)
+			}()
+		}
+		stopServiceWG.Wait()
+	}
+}
@@ -0,0 +1,140 @@
+package manager
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// dependencyGraph returns services named after their IDs, each depending on
+// the services listed for it.
+func dependencyGraph(dependsOn map[string][]string) map[string]*service {
+	services := make(map[string]*service, len(dependsOn))
+	for id, dependencyIDs := range dependsOn {
+		var dependencies []Dependency
+		for _, dependencyID := range dependencyIDs {
+			dependencies = append(dependencies, Dependency{ServiceID: dependencyID})
+		}
+
+		services[id] = &service{
+			ID:     id,
+			Name:   id,
+			Config: ServiceConfig{DependsOn: dependencies},
+		}
+	}
+	return services
+}
+
+func serviceIDs(services []*service) []string {
+	ids := make([]string, 0, len(services))
+	for _, service := range services {
+		ids = append(ids, service.ID)
+	}
+	return ids
+}
+
+func TestDependencyOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		dependsOn map[string][]string
+		serviceID string
+		// Every service must come after the services it depends on
+		wantIDs []string
+		wantErr string
+	}{
+		{
+			name:      "no dependencies",
+			dependsOn: map[string][]string{"a": nil},
+			serviceID: "a",
+			wantIDs:   []string{"a"},
+		},
+		{
+			name:      "chain",
+			dependsOn: map[string][]string{"a": {"b"}, "b": {"c"}, "c": nil},
+			serviceID: "a",
+			wantIDs:   []string{"c", "b", "a"},
+		},
+		{
+			name:      "diamond",
+			dependsOn: map[string][]string{"a": {"b", "c"}, "b": {"d"}, "c": {"d"}, "d": nil},
+			serviceID: "a",
+			wantIDs:   []string{"d", "b", "c", "a"},
+		},
+		{
+			name:      "only transitive dependencies",
+			dependsOn: map[string][]string{"a": {"b"}, "b": nil, "c": {"a"}},
+			serviceID: "a",
+			wantIDs:   []string{"b", "a"},
+		},
+		{
+			name:      "self dependency",
+			dependsOn: map[string][]string{"a": {"a"}},
+			serviceID: "a",
+			wantErr:   "dependency cycle: 'a' -> 'a'",
+		},
+		{
+			name:      "cycle",
+			dependsOn: map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}},
+			serviceID: "a",
+			wantErr:   "dependency cycle: 'a' -> 'b' -> 'c' -> 'a'",
+		},
+		{
+			name:      "cycle below the service",
+			dependsOn: map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"b"}},
+			serviceID: "a",
+			wantErr:   "dependency cycle: 'a' -> 'b' -> 'c' -> 'b'",
+		},
+		{
+			name:      "unknown dependency",
+			dependsOn: map[string][]string{"a": {"missing"}},
+			serviceID: "a",
+			wantErr:   "service 'missing' not found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			order, err := dependencyOrder(dependencyGraph(test.dependsOn), test.serviceID)
+
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error is %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := serviceIDs(order)
+			if !slices.Equal(got, test.wantIDs) {
+				t.Fatalf("order is %v, want %v", got, test.wantIDs)
+			}
+		})
+	}
+}
+
+func TestDependentsOrder(t *testing.T) {
+	services := dependencyGraph(map[string][]string{
+		"db":     nil,
+		"api":    {"db"},
+		"worker": {"db"},
+		"web":    {"api"},
+		"other":  nil,
+	})
+
+	order := serviceIDs(dependentsOrder(services, "db"))
+
+	if len(order) != 3 || slices.Contains(order, "db") || slices.Contains(order, "other") {
+		t.Fatalf("dependents of db are %v, want api, web and worker", order)
+	}
+
+	// Every service is stopped before the services it depends on
+	position := make(map[string]int)
+	for i, id := range order {
+		position[id] = i
+	}
+	if position["web"] > position["api"] {
+		t.Fatalf("web is stopped after api it depends on: %v", order)
+	}
+}
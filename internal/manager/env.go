@@ -0,0 +1,85 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// Matches ${VAR} and ${VAR:-default}
+var envReferencePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+func validateEnv(env map[string]string) error {
+	for key := range env {
+		if key == "" || strings.ContainsAny(key, "=\x00") {
+			return fmt.Errorf("invalid environment variable name '%s'", key)
+		}
+	}
+	return nil
+}
+
+// interpolateEnv replaces ${VAR} references with values from the manager's environment.
+func interpolateEnv(value string) string {
+	return envReferencePattern.ReplaceAllStringFunc(value, func(reference string) string {
+		match := envReferencePattern.FindStringSubmatch(reference)
+
+		if envValue, ok := os.LookupEnv(match[1]); ok && envValue != "" {
+			return envValue
+		}
+		return match[2]
+	})
+}
+
+// buildEnv returns the environment of the service process. Variables from env files
+// override the inherited environment and the identity of the user the service
+// runs as, and the env map overrides them all.
+func (s *service) buildEnv() ([]string, error) {
+	// Not nil even when empty, a nil environment makes exec.Cmd inherit the
+	// manager's one
+	env := []string{}
+	if !s.Config.CleanEnv {
+		env = os.Environ()
+	}
+
+	env = append(env, s.Config.identityEnv()...)
+
+	for _, envFile := range s.Config.EnvFiles {
+		if !filepath.IsAbs(envFile) {
+			envFile = filepath.Join(s.ExecuteDirectory, envFile)
+		}
+
+		fileEnv, err := godotenv.Read(envFile)
+		if err != nil {
+			return nil, fmt.Errorf("read env file: %w", err)
+		}
+
+		env = appendEnvMap(env, fileEnv, false)
+	}
+
+	return appendEnvMap(env, s.Config.Env, true), nil
+}
+
+// appendEnvMap appends the variables in key order so the result is stable.
+// exec.Cmd keeps the last value of duplicated keys.
+func appendEnvMap(env []string, envMap map[string]string, interpolate bool) []string {
+	keys := make([]string, 0, len(envMap))
+	for key := range envMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := envMap[key]
+		if interpolate {
+			value = interpolateEnv(value)
+		}
+		env = append(env, key+"="+value)
+	}
+
+	return env
+}
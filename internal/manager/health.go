@@ -0,0 +1,238 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"time"
+)
+
+const (
+	DEFAULT_HEALTH_CHECK_INTERVAL  = 10 * time.Second
+	DEFAULT_HEALTH_CHECK_TIMEOUT   = 5 * time.Second
+	DEFAULT_HEALTH_CHECK_THRESHOLD = 3
+)
+
+// errUnhealthy ends a run that was restarted because its health checks failed
+var errUnhealthy = errors.New("service is unhealthy")
+
+func (check HealthCheck) enabled() bool {
+	return check.Type != ""
+}
+
+func (check HealthCheck) validate() error {
+	switch check.Type {
+	case "":
+		return nil
+	case HEALTH_CHECK_HTTP:
+		parsedURL, err := url.Parse(check.URL)
+		if err != nil || parsedURL.Host == "" {
+			return fmt.Errorf("invalid health check url '%s'", check.URL)
+		}
+	case HEALTH_CHECK_TCP:
+		if _, _, err := net.SplitHostPort(check.Address); err != nil {
+			return fmt.Errorf("invalid health check address '%s': %w", check.Address, err)
+		}
+	case HEALTH_CHECK_EXEC:
+		if check.Command.Name == "" {
+			return errors.New("health check command name cannot be empty")
+		}
+	default:
+		return fmt.Errorf("unknown health check type '%s'", check.Type)
+	}
+
+	if check.IntervalSeconds < 0 || check.TimeoutSeconds < 0 || check.StartPeriodSeconds < 0 {
+		return errors.New("health check durations cannot be negative")
+	}
+
+	if check.FailureThreshold < 0 {
+		return errors.New("health check failure threshold cannot be negative")
+	}
+
+	return nil
+}
+
+func (check HealthCheck) interval() time.Duration {
+	if check.IntervalSeconds == 0 {
+		return DEFAULT_HEALTH_CHECK_INTERVAL
+	}
+	return secondsToDuration(check.IntervalSeconds)
+}
+
+func (check HealthCheck) timeout() time.Duration {
+	if check.TimeoutSeconds == 0 {
+		return DEFAULT_HEALTH_CHECK_TIMEOUT
+	}
+	return secondsToDuration(check.TimeoutSeconds)
+}
+
+func (check HealthCheck) failureThreshold() int {
+	if check.FailureThreshold == 0 {
+		return DEFAULT_HEALTH_CHECK_THRESHOLD
+	}
+	return check.FailureThreshold
+}
+
+// initialStatus is the status of a freshly started process.
+func (check HealthCheck) initialStatus() ServiceStatus {
+	if check.enabled() {
+		return SERVICE_STARTING
+	}
+	return SERVICE_RUNNING
+}
+
+func (s *service) probe(ctx context.Context) error {
+	check := s.Config.HealthCheck
+
+	ctx, cancel := context.WithTimeout(ctx, check.timeout())
+	defer cancel()
+
+	switch check.Type {
+	case HEALTH_CHECK_HTTP:
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, check.URL, nil)
+		if err != nil {
+			return err
+		}
+
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+
+		if check.ExpectedStatus != 0 && response.StatusCode != check.ExpectedStatus {
+			return fmt.Errorf("unexpected status code %d", response.StatusCode)
+		}
+		if check.ExpectedStatus == 0 && (response.StatusCode < 200 || response.StatusCode > 299) {
+			return fmt.Errorf("unexpected status code %d", response.StatusCode)
+		}
+
+		return nil
+
+	case HEALTH_CHECK_TCP:
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", check.Address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+
+	case HEALTH_CHECK_EXEC:
+		cmd := exec.CommandContext(ctx, check.Command.Name, check.Command.Arguments...)
+		cmd.Dir = s.ExecuteDirectory
+
+		env, err := s.buildEnv()
+		if err != nil {
+			return err
+		}
+		cmd.Env = env
+
+		// The command must not run with more privileges than the service
+		if err := s.Config.runAsService(cmd); err != nil {
+			return err
+		}
+
+		err = cmd.Run()
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) || err == nil {
+			if exitCode := cmd.ProcessState.ExitCode(); exitCode != check.ExpectedExitCode {
+				return fmt.Errorf("unexpected exit code %d", exitCode)
+			}
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// updateHealth records a check result. The status is only changed while the
+// process is alive, so a late result cannot bring a stopped service back.
+func (s *service) updateHealth(status ServiceStatus, failures int, checkErr error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.status.IsActive() {
+		s.changeStatus(status)
+	}
+
+	s.health = HealthState{
+		ConsecutiveFailures: failures,
+		LastCheckAt:         time.Now(),
+	}
+	if checkErr != nil {
+		s.health.LastError = checkErr.Error()
+	}
+}
+
+func (s *service) GetHealth() HealthState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.health
+}
+
+// monitorHealth runs the health check of the service until the context is done.
+// restartRun is called once the service becomes unhealthy if it should be restarted.
+func (s *service) monitorHealth(ctx context.Context, restartRun func()) {
+	check := s.Config.HealthCheck
+	if !check.enabled() {
+		return
+	}
+
+	// Results of the previous run do not apply to this one
+	s.mutex.Lock()
+	s.health = HealthState{}
+	s.mutex.Unlock()
+
+	startPeriodEnd := time.Now().Add(secondsToDuration(check.StartPeriodSeconds))
+	failures := 0
+
+	ticker := time.NewTicker(check.interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := s.probe(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err == nil {
+			failures = 0
+			s.updateHealth(SERVICE_HEALTHY, failures, nil)
+			continue
+		}
+
+		// Failures do not count until the service has had time to come up
+		if s.GetStatus() == SERVICE_STARTING && time.Now().Before(startPeriodEnd) {
+			s.updateHealth(SERVICE_STARTING, failures, err)
+			continue
+		}
+
+		failures++
+		if failures < check.failureThreshold() {
+			s.updateHealth(s.GetStatus(), failures, err)
+			continue
+		}
+
+		s.updateHealth(SERVICE_UNHEALTHY, failures, err)
+
+		if check.RestartOnUnhealthy {
+			log.Printf("service '%s' (ID: '%s') is unhealthy, restarting: %v", s.Name, s.ID, err)
+			restartRun()
+			return
+		}
+	}
+}
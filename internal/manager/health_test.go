@@ -0,0 +1,155 @@
+package manager
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// healthTestService returns a started service whose TCP health check probes
+// address, and a channel receiving its status changes.
+func healthTestService(address string, check HealthCheck) (*service, <-chan ServiceStatus) {
+	statuses := make(chan ServiceStatus, 100)
+
+	check.Type = HEALTH_CHECK_TCP
+	check.Address = address
+	check.IntervalSeconds = 0.01
+	check.TimeoutSeconds = 0.5
+
+	s := &service{
+		ID:     "health",
+		Name:   "health",
+		Config: ServiceConfig{HealthCheck: check},
+		status: check.initialStatus(),
+		statusChanged: func(change StatusChange) {
+			statuses <- change.Status
+		},
+	}
+
+	return s, statuses
+}
+
+// waitForStatus returns the status changes until want, failing if it does not
+// come.
+func waitForStatus(t *testing.T, statuses <-chan ServiceStatus, want ServiceStatus) []ServiceStatus {
+	t.Helper()
+
+	var seen []ServiceStatus
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case status := <-statuses:
+			seen = append(seen, status)
+			if status == want {
+				return seen
+			}
+		case <-timeout:
+			t.Fatalf("status %s not reached, changes were %v", want, seen)
+		}
+	}
+}
+
+// closedAddress returns an address nothing listens on.
+func closedAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	return address
+}
+
+func TestMonitorHealthBecomesHealthy(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	s, statuses := healthTestService(listener.Addr().String(), HealthCheck{})
+	if s.GetStatus() != SERVICE_STARTING {
+		t.Fatalf("a service with a health check starts as %s, want %s", s.GetStatus(), SERVICE_STARTING)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.monitorHealth(ctx, func() { t.Error("a healthy service was restarted") })
+
+	waitForStatus(t, statuses, SERVICE_HEALTHY)
+	if health := s.GetHealth(); health.ConsecutiveFailures != 0 || health.LastError != "" {
+		t.Fatalf("health of a healthy service is %+v", health)
+	}
+}
+
+func TestMonitorHealthBecomesUnhealthy(t *testing.T) {
+	tests := []struct {
+		name               string
+		failureThreshold   int
+		restartOnUnhealthy bool
+	}{
+		{name: "default threshold", failureThreshold: 0},
+		{name: "threshold of 1", failureThreshold: 1},
+		{name: "restart on unhealthy", failureThreshold: 2, restartOnUnhealthy: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			check := HealthCheck{FailureThreshold: test.failureThreshold, RestartOnUnhealthy: test.restartOnUnhealthy}
+			s, statuses := healthTestService(closedAddress(t), check)
+
+			restarted := make(chan struct{})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go s.monitorHealth(ctx, func() { close(restarted) })
+
+			seen := waitForStatus(t, statuses, SERVICE_UNHEALTHY)
+			if len(seen) != 1 {
+				t.Fatalf("status changes were %v, want only %s", seen, SERVICE_UNHEALTHY)
+			}
+
+			if failures := s.GetHealth().ConsecutiveFailures; failures != check.failureThreshold() {
+				t.Fatalf("unhealthy after %d failures, want %d", failures, check.failureThreshold())
+			}
+
+			if test.restartOnUnhealthy {
+				select {
+				case <-restarted:
+				case <-time.After(5 * time.Second):
+					t.Fatal("the unhealthy service was not restarted")
+				}
+			}
+		})
+	}
+}
+
+func TestMonitorHealthStartPeriod(t *testing.T) {
+	s, statuses := healthTestService(closedAddress(t), HealthCheck{FailureThreshold: 1, StartPeriodSeconds: 0.3})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startedAt := time.Now()
+	go s.monitorHealth(ctx, func() {})
+
+	waitForStatus(t, statuses, SERVICE_UNHEALTHY)
+	if elapsed := time.Since(startedAt); elapsed < 300*time.Millisecond {
+		t.Fatalf("unhealthy after %v, during the start period", elapsed)
+	}
+}
+
+func TestUpdateHealthKeepsStoppedService(t *testing.T) {
+	s, statuses := healthTestService(closedAddress(t), HealthCheck{})
+	s.status = SERVICE_STOPPED
+
+	s.updateHealth(SERVICE_HEALTHY, 0, nil)
+
+	if s.GetStatus() != SERVICE_STOPPED {
+		t.Fatalf("a late result changed a stopped service to %s", s.GetStatus())
+	}
+	select {
+	case status := <-statuses:
+		t.Fatalf("status changed to %s", status)
+	default:
+	}
+}
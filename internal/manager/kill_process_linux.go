@@ -4,12 +4,67 @@ package manager
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"os"
+	"strings"
 	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
 )
 
-func killProcessOnCancel(killContext context.Context, serviceName string, PID int) {
-	<-killContext.Done()
+var stopSignals = map[string]syscall.Signal{
+	"SIGTERM": syscall.SIGTERM,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+	"SIGKILL": syscall.SIGKILL,
+}
+
+func parseStopSignal(name string) (syscall.Signal, bool) {
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+
+	signal, ok := stopSignals[name]
+	return signal, ok
+}
+
+func validateStopSignal(name string) error {
+	if _, ok := parseStopSignal(name); !ok {
+		return fmt.Errorf("unsupported stop signal '%s'", name)
+	}
+	return nil
+}
+
+// exitSignal returns the name of the signal that terminated the process, if any
+func exitSignal(state *os.ProcessState) string {
+	waitStatus, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !waitStatus.Signaled() {
+		return ""
+	}
+	return unix.SignalName(waitStatus.Signal())
+}
+
+// killProcessOnCancel waits for the context to be cancelled, sends the stop signal
+// to the process group and kills the group if the process has not exited after
+// the grace period. It returns true if the process had to be killed.
+func killProcessOnCancel(
+	killContext context.Context,
+	serviceName string,
+	PID int,
+	exited <-chan struct{},
+	stopParams func() (string, time.Duration),
+) bool {
+	select {
+	case <-exited:
+		return false
+	case <-killContext.Done():
+	}
 
 	log.Printf("Context cancelled for service %s", serviceName)
 
@@ -17,13 +72,36 @@ func killProcessOnCancel(killContext context.Context, serviceName string, PID in
 	pgid, err := syscall.Getpgid(PID)
 	if err != nil {
 		log.Printf("Getpgid failed: %v", err)
-		return
+		return false
+	}
+
+	signalName, gracePeriod := stopParams()
+	signal, _ := parseStopSignal(signalName)
+
+	if signal != syscall.SIGKILL {
+		if err := syscall.Kill(-pgid, signal); err != nil {
+			log.Printf("Failed to send %s to process group: %v", signalName, err)
+		}
+
+		timer := time.NewTimer(gracePeriod)
+		defer timer.Stop()
+
+		select {
+		case <-exited:
+			// Clean up whatever the service left behind in its process group
+			syscall.Kill(-pgid, syscall.SIGKILL)
+			log.Printf("Stopped service %s gracefully (pid %d, pgid %d)", serviceName, PID, pgid)
+			return false
+		case <-timer.C:
+			log.Printf("Service %s did not exit within %v", serviceName, gracePeriod)
+		}
 	}
 
 	if err := syscall.Kill(-pgid, syscall.SIGKILL); err != nil {
 		log.Printf("Failed to kill process group: %v", err)
-		return
+		return false
 	}
 
 	log.Printf("Killed service %s (pid %d, pgid %d)", serviceName, PID, pgid)
+	return true
 }
@@ -5,21 +5,62 @@ package manager
 import (
 	"context"
 	"log"
+	"os"
 	"os/exec"
 	"strconv"
+	"time"
 )
 
-func killProcessOnCancel(killContext context.Context, serviceName string, PID int) {
-	<-killContext.Done()
+// Windows has no signals, the stop signal of a service is ignored
+func validateStopSignal(name string) error {
+	return nil
+}
+
+// Processes are never terminated by a signal on Windows
+func exitSignal(state *os.ProcessState) string {
+	return ""
+}
+
+func killProcessOnCancel(
+	killContext context.Context,
+	serviceName string,
+	PID int,
+	exited <-chan struct{},
+	stopParams func() (string, time.Duration),
+) bool {
+	select {
+	case <-exited:
+		return false
+	case <-killContext.Done():
+	}
 
 	log.Printf("Context cancelled for service %s", serviceName)
 
+	_, gracePeriod := stopParams()
+
+	// Without /F taskkill asks the process tree to close, which only works for
+	// processes that handle window messages
+	closeCmd := exec.Command("taskkill", "/T", "/PID", strconv.Itoa(PID))
+	if err := closeCmd.Run(); err == nil {
+		timer := time.NewTimer(gracePeriod)
+		defer timer.Stop()
+
+		select {
+		case <-exited:
+			log.Printf("Stopped service %s gracefully", serviceName)
+			return false
+		case <-timer.C:
+			log.Printf("Service %s did not exit within %v", serviceName, gracePeriod)
+		}
+	}
+
 	// Have to run taskkill manually because fucking Windows refuses to play nicely with process kill
 	// Fuck Microsoft
 	killCmd := exec.Command("taskkill", "/F", "/T", "/PID", strconv.Itoa(PID))
 	if err := killCmd.Run(); err != nil {
 		log.Printf("taskkill failed: %v", err)
-		return
+		return false
 	}
 	log.Printf("taskkill service %s successfully", serviceName)
+	return true
 }
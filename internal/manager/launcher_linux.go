@@ -0,0 +1,193 @@
+//go:build linux
+
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"syscall"
+)
+
+const (
+	// Environment variable holding the launch spec when the manager's own
+	// executable is started as the launcher of a service
+	LAUNCHER_ENV = "SERVICE_MANAGER_LAUNCH"
+	// Exit code of the launcher when it cannot execute the command
+	LAUNCHER_EXIT_CODE = 127
+)
+
+// launchSpec holds the process settings exec.Cmd cannot apply, which the
+// launcher applies to itself before it executes the command in its place.
+type launchSpec struct {
+	Path  string   `json:"path"`
+	Args  []string `json:"args"`
+	Umask *uint32  `json:"umask,omitempty"`
+	// The launcher starts as the manager, which may be needed to set the
+	// sandbox and limits up, and then switches to the user of the service
+	Credential *syscall.Credential `json:"credential,omitempty"`
+	Sandbox    Sandbox             `json:"sandbox"`
+	Rlimits    map[string]Rlimit   `json:"rlimits,omitempty"`
+	Scheduling Scheduling          `json:"scheduling"`
+}
+
+// RunLauncherIfRequested turns the process into the launcher of a service if
+// it was started as one, in which case it does not return. It must be called
+// first in main, before anything reads or changes the environment.
+func RunLauncherIfRequested() {
+	encoded, ok := os.LookupEnv(LAUNCHER_ENV)
+	if !ok {
+		return
+	}
+	os.Unsetenv(LAUNCHER_ENV)
+
+	// The scheduling settings only apply to the thread executing the command
+	runtime.LockOSThread()
+
+	var spec launchSpec
+	if err := json.Unmarshal([]byte(encoded), &spec); err != nil {
+		launcherFail("decode launch spec: %v", err)
+	}
+
+	if err := spec.Sandbox.setUp(); err != nil {
+		launcherFail("set up sandbox: %v", err)
+	}
+
+	if err := applyRlimits(spec.Rlimits); err != nil {
+		launcherFail("%v", err)
+	}
+
+	if err := spec.Scheduling.apply(); err != nil {
+		launcherFail("%v", err)
+	}
+
+	if spec.Umask != nil {
+		syscall.Umask(int(*spec.Umask))
+	}
+
+	if spec.Sandbox.PrivatePID {
+		runInit(spec)
+	}
+
+	if spec.Credential != nil {
+		if err := switchCredential(spec.Credential); err != nil {
+			launcherFail("switch user: %v", err)
+		}
+	}
+
+	err := syscall.Exec(spec.Path, spec.Args, os.Environ())
+	launcherFail("execute %s: %v", spec.Path, err)
+}
+
+// runInit runs the command as a child of the launcher, which stays the init
+// of the PID namespace: it reaps the orphaned processes and exits with the
+// command. A command killed by a signal exits with 128 plus the signal
+// number, as the init of a namespace cannot be killed by its own signal.
+func runInit(spec launchSpec) {
+	// Signals are caught, otherwise the init of a namespace does not receive
+	// them, and the command gets them with the default handlers
+	signals := make(chan os.Signal, 16)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	cmd := &exec.Cmd{
+		Path:        spec.Path,
+		Args:        spec.Args,
+		Env:         os.Environ(),
+		Stdin:       os.Stdin,
+		Stdout:      os.Stdout,
+		Stderr:      os.Stderr,
+		SysProcAttr: &syscall.SysProcAttr{Credential: spec.Credential},
+	}
+	if err := cmd.Start(); err != nil {
+		launcherFail("execute %s: %v", spec.Path, err)
+	}
+	pid := cmd.Process.Pid
+
+	go func() {
+		for sig := range signals {
+			// Signals sent to the process group reach the command already,
+			// unless it moved to a group of its own
+			pgid, err := syscall.Getpgid(pid)
+			if err == nil && pgid != syscall.Getpgrp() {
+				syscall.Kill(pid, sig.(syscall.Signal))
+			}
+		}
+	}()
+
+	for {
+		var status syscall.WaitStatus
+		exited, err := syscall.Wait4(-1, &status, 0, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			launcherFail("wait for %s: %v", spec.Path, err)
+		}
+
+		if exited != pid {
+			continue
+		}
+
+		// The remaining processes of the namespace are killed when its init exits
+		if status.Signaled() {
+			os.Exit(128 + int(status.Signal()))
+		}
+		os.Exit(status.ExitStatus())
+	}
+}
+
+// switchCredential makes every thread of the launcher run as the user of the
+// service.
+func switchCredential(credential *syscall.Credential) error {
+	if !credential.NoSetGroups {
+		groups := make([]int, len(credential.Groups))
+		for i, gid := range credential.Groups {
+			groups[i] = int(gid)
+		}
+		if err := syscall.Setgroups(groups); err != nil {
+			return err
+		}
+	}
+
+	// The group is changed first, as changing the user drops the privilege
+	if err := syscall.Setgid(int(credential.Gid)); err != nil {
+		return err
+	}
+
+	return syscall.Setuid(int(credential.Uid))
+}
+
+func launcherFail(format string, args ...any) {
+	// Written to the stderr log of the service
+	fmt.Fprintf(os.Stderr, "service-manager launcher: "+format+"\n", args...)
+	os.Exit(LAUNCHER_EXIT_CODE)
+}
+
+// useLauncher makes cmd start the manager's own executable as the launcher of
+// the command, which applies spec. The pid stays the one of the command.
+func useLauncher(cmd *exec.Cmd, spec launchSpec) error {
+	if cmd.Err != nil {
+		// The command was not found, cmd.Start reports it
+		return nil
+	}
+
+	spec.Path = cmd.Path
+	spec.Args = cmd.Args
+
+	encoded, err := json.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("encode launch spec: %w", err)
+	}
+
+	// cmd.Env is the whole environment of the command, built by buildEnv
+	// from the manager's one unless clean_env is set. The launcher removes
+	// its variable before executing the command.
+	cmd.Env = append(cmd.Env, LAUNCHER_ENV+"="+string(encoded))
+
+	cmd.Path = "/proc/self/exe"
+
+	return nil
+}
@@ -0,0 +1,7 @@
+//go:build windows
+
+package manager
+
+// RunLauncherIfRequested does nothing on Windows, where services are started
+// directly.
+func RunLauncherIfRequested() {}
@@ -7,15 +7,26 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"strings"
+	"service-manager/internal/logstore"
 	"sync"
+	"time"
 )
 
 type ServiceManager struct {
 	services         map[string]*service
 	logsDir          string
 	servicesDataPath string
-	readWriteMutex   sync.RWMutex
+	// Entries of the services file that could not be loaded, written back
+	// unchanged so that their configuration is not lost
+	unloadedServices []serviceData
+	logRotation      logstore.RotationPolicy
+	// Cgroup v2 slice of the services with resource limits, relative to the cgroup mount
+	cgroupSlice       string
+	logHub            *logstore.Hub
+	statusBroadcaster *statusBroadcaster
+	readWriteMutex    sync.RWMutex
+	startupSummary    StartupSummary
+	summaryMutex      sync.Mutex
 }
 
 func (sm *ServiceManager) GetService(serviceID string) (*service, error) {
@@ -46,43 +57,76 @@ func (sm *ServiceManager) ServiceExists(serviceID string) bool {
 	return ok
 }
 
-func (sm *ServiceManager) stdoutHandler(service *service, line string) {
-	// Full log path: <logsDir>/<service ID>/stdout
-	subDir := service.ID
-	fileName := "stdout"
-	fullPath := filepath.Join(sm.logsDir, subDir, fileName)
+// serviceLog captures the lines of a service stream. Every line is numbered,
+// published to the log hub for live subscribers and written to the log file.
+type serviceLog struct {
+	serviceID  string
+	streamName string
+	sequence   *logstore.Sequence
+	hub        *logstore.Hub
+	writer     *logstore.Writer
+}
+
+func (l *serviceLog) WriteLine(line string) {
+	entry := l.sequence.Stamp(l.streamName, line, func(entry logstore.Entry) {
+		l.hub.Publish(l.serviceID, entry)
+	})
 
-	file, err := openAppend(fullPath)
+	l.writer.Append(entry)
+}
+
+func (l *serviceLog) Close() error {
+	return l.writer.Close()
+}
+
+// openLog opens the log of a service stream for the duration of a run.
+func (sm *ServiceManager) openLog(service *service, streamName string) (logSink, error) {
+	sequence, err := sm.logSequence(service)
 	if err != nil {
-		log.Println("failed to open file: ", err)
+		return nil, err
 	}
-	defer file.Close()
 
-	formattedLine := fmt.Sprintf("%s\n", strings.TrimSpace(line))
+	// Full log path: <logsDir>/<service ID>/<stream name>
+	fullPath := filepath.Join(sm.logsDir, service.ID, streamName)
+	policy := sm.logRotation.Merge(service.Config.LogRotation)
+
+	return &serviceLog{
+		serviceID:  service.ID,
+		streamName: streamName,
+		sequence:   sequence,
+		hub:        sm.logHub,
+		writer:     logstore.NewWriter(fullPath, policy),
+	}, nil
+}
+
+// logSequence returns the sequence shared by the streams of a service. It is
+// created on first use and continues after the entries already logged.
+func (sm *ServiceManager) logSequence(service *service) (*logstore.Sequence, error) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
 
-	if _, err := file.Write([]byte(formattedLine)); err != nil {
-		log.Println("failed to write to file: ", err)
+	if service.logSequence != nil {
+		return service.logSequence, nil
 	}
-}
 
-func (sm *ServiceManager) stderrHandler(service *service, line string) {
-	// Full log path: <logsDir>/<service ID>/stderr
-	subDir := service.ID
-	fileName := "stderr"
-	fullPath := filepath.Join(sm.logsDir, subDir, fileName)
+	serviceLogDir := filepath.Join(sm.logsDir, service.ID)
 
-	file, err := openAppend(fullPath)
+	sequence, err := logstore.NewSequence(
+		filepath.Join(serviceLogDir, "stdout"),
+		filepath.Join(serviceLogDir, "stderr"),
+	)
 	if err != nil {
-		log.Println("failed to open file: ", err)
+		return nil, fmt.Errorf("failed to read log sequence of service '%s' (ID: '%s'): %w", service.Name, service.ID, err)
 	}
-	defer file.Close()
 
-	formattedLine := fmt.Sprintf("%s\n", strings.TrimSpace(line))
+	service.logSequence = sequence
 
-	if _, err := file.Write([]byte(formattedLine)); err != nil {
-		log.Println("failed to write to file: ", err)
-	}
+	return sequence, nil
+}
 
+// LogHub returns the hub the captured log lines of all services are published to.
+func (sm *ServiceManager) LogHub() *logstore.Hub {
+	return sm.logHub
 }
 
 func (sm *ServiceManager) GetAllServices() []*service {
@@ -99,23 +143,54 @@ func (sm *ServiceManager) GetAllServices() []*service {
 	return servicesSlice
 }
 
+// checkHost runs the checks of a configuration that depend on the host, such as
+// whether its user exists or the manager runs as root. The host can change
+// after a service is registered, so a service loaded from the services file is
+// kept even if they fail, and its start fails instead.
+func (config ServiceConfig) checkHost() error {
+	if err := checkRunAs(config); err != nil {
+		return fmt.Errorf("invalid run as: %w", err)
+	}
+
+	if err := config.Sandbox.check(); err != nil {
+		return fmt.Errorf("invalid sandbox: %w", err)
+	}
+
+	if err := checkRlimits(config.Rlimits); err != nil {
+		return fmt.Errorf("invalid rlimits: %w", err)
+	}
+
+	if err := config.Scheduling.check(); err != nil {
+		return fmt.Errorf("invalid scheduling: %w", err)
+	}
+
+	return nil
+}
+
 func (sm *ServiceManager) RegisterService(
 	serviceName string,
 	commandName string,
 	commandArgs []string,
 	executeDirectory string,
+	config ServiceConfig,
 ) error {
 	sm.readWriteMutex.Lock()
 	defer sm.readWriteMutex.Unlock()
 
+	if err := config.checkHost(); err != nil {
+		return fmt.Errorf("register service: %w", err)
+	}
+
 	service, err := newService(
 		"",
 		serviceName,
 		commandName,
 		commandArgs,
 		executeDirectory,
-		sm.stdoutHandler,
-		sm.stderrHandler,
+		config,
+		sm.openLog,
+		sm.statusBroadcaster.publish,
+		sm.cgroupSlice,
 	)
 	if err != nil {
 		return fmt.Errorf("register service: %w", err)
@@ -123,10 +198,15 @@ func (sm *ServiceManager) RegisterService(
 
 	sm.services[service.ID] = service
 
+	if err := validateDependencies(sm.services, service.ID); err != nil {
+		delete(sm.services, service.ID)
+		return fmt.Errorf("register service: %w", err)
+	}
+
 	return sm.updateServicesFile()
 }
 
-func (sm *ServiceManager) loadService(serviceID, serviceName, executeDirectory string, command Command) error {
+func (sm *ServiceManager) loadService(serviceID, serviceName, executeDirectory string, command Command, config ServiceConfig) error {
 	sm.readWriteMutex.Lock()
 	defer sm.readWriteMutex.Unlock()
 
@@ -140,8 +220,10 @@ func (sm *ServiceManager) loadService(serviceID, serviceName, executeDirectory s
 		command.Name,
 		command.Arguments,
 		executeDirectory,
-		sm.stdoutHandler,
-		sm.stderrHandler,
+		config,
+		sm.openLog,
+		sm.statusBroadcaster.publish,
+		sm.cgroupSlice,
 	)
 	if err != nil {
 		return fmt.Errorf("load service: %w", err)
@@ -172,9 +254,23 @@ func (sm *ServiceManager) LoadServices() error {
 			serviceData.Name,
 			serviceData.ExecuteDirectory,
 			serviceData.Cmd,
+			serviceData.Config,
 		)
 		if err != nil {
-			return fmt.Errorf("error loading service: %w", err)
+			// The other services are still loaded
+			log.Printf("error loading service '%s' (ID: '%s'): %v", serviceData.Name, serviceData.ID, err)
+			sm.readWriteMutex.Lock()
+			sm.unloadedServices = append(sm.unloadedServices, serviceData)
+			sm.readWriteMutex.Unlock()
+		}
+	}
+
+	sm.readWriteMutex.RLock()
+	defer sm.readWriteMutex.RUnlock()
+
+	for serviceID, service := range sm.services {
+		if err := validateDependencies(sm.services, serviceID); err != nil {
+			return fmt.Errorf("error loading service '%s': %w", service.Name, err)
 		}
 	}
 
@@ -196,10 +292,12 @@ func (sm *ServiceManager) updateServicesFile() error {
 			Name:             service.Name,
 			Cmd:              service.Cmd,
 			ExecuteDirectory: service.ExecuteDirectory,
+			Config:           service.Config,
 		}
 
 		servicesData = append(servicesData, serviceData)
 	}
+	servicesData = append(servicesData, sm.unloadedServices...)
 
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ")
@@ -224,7 +322,14 @@ func (sm *ServiceManager) RemoveService(serviceID string) error {
 		return fmt.Errorf("service '%s' (ID: '%s') is running, cannot remove", service.Name, service.ID)
 	}
 
+	for _, other := range sm.services {
+		if other.dependsOn(serviceID) {
+			return fmt.Errorf("service '%s' depends on service '%s', cannot remove", other.Name, service.Name)
+		}
+	}
+
 	delete(sm.services, serviceID)
+	sm.logHub.Remove(serviceID)
 
 	serviceLogDir := filepath.Join(sm.logsDir, serviceID)
 
@@ -236,62 +341,112 @@ func (sm *ServiceManager) RemoveService(serviceID string) error {
 	return sm.updateServicesFile()
 }
 
+// StartService starts a service after its dependencies, in topological order.
+// Each service waits for the conditions of its own dependencies before starting.
 func (sm *ServiceManager) StartService(serviceID string) error {
 	sm.readWriteMutex.RLock()
-	defer sm.readWriteMutex.RUnlock()
 
 	service, ok := sm.services[serviceID]
-
 	if !ok {
+		sm.readWriteMutex.RUnlock()
 		return fmt.Errorf("service with ID '%s' not found", serviceID)
 	}
 
-	err := service.Start(context.Background())
+	if service.GetStatus() != SERVICE_STOPPED {
+		sm.readWriteMutex.RUnlock()
+		return fmt.Errorf("error starting service '%s' (ID: '%s'). error: service is already running", service.Name, service.ID)
+	}
+
+	order, err := dependencyOrder(sm.services, serviceID)
 	if err != nil {
+		sm.readWriteMutex.RUnlock()
 		return fmt.Errorf("error starting service '%s' (ID: '%s'). error: %v", service.Name, service.ID, err)
 	}
 
+	sm.readWriteMutex.RUnlock()
+
+	// Every dependency is part of the order, waiting on them does not need the lock
+	dependencies := servicesByID(order)
+
+	for _, orderedService := range order {
+		for _, dependency := range orderedService.Config.DependsOn {
+			if err := waitForDependency(dependency, dependencies[dependency.ServiceID]); err != nil {
+				return fmt.Errorf("error starting service '%s' (ID: '%s'). error: %v", orderedService.Name, orderedService.ID, err)
+			}
+		}
+
+		if orderedService.ID != serviceID && orderedService.GetStatus() != SERVICE_STOPPED {
+			continue
+		}
+
+		err := orderedService.Start(context.Background())
+		if err != nil {
+			return fmt.Errorf("error starting service '%s' (ID: '%s'). error: %v", orderedService.Name, orderedService.ID, err)
+		}
+	}
+
 	return nil
 }
 
-func (sm *ServiceManager) StopService(serviceID string) error {
+// StopService stops a service after the services that depend on it.
+// gracePeriod overrides the stop timeout of every stopped service when not nil.
+// It returns true if the service exited gracefully.
+func (sm *ServiceManager) StopService(serviceID string, gracePeriod *time.Duration) (bool, error) {
 	sm.readWriteMutex.RLock()
 	defer sm.readWriteMutex.RUnlock()
 
 	service, ok := sm.services[serviceID]
 
 	if !ok {
-		return fmt.Errorf("service with ID '%s' not found", serviceID)
+		return false, fmt.Errorf("service with ID '%s' not found", serviceID)
 	}
 
-	err := service.Stop()
-	if err != nil {
-		return fmt.Errorf("failed to stop service '%s' (ID: '%s'). Error: %v", service.Name, service.ID, err)
+	if service.GetStatus() == SERVICE_STOPPED {
+		return false, fmt.Errorf("failed to stop service '%s' (ID: '%s'). Error: service is not running", service.Name, service.ID)
 	}
 
-	return nil
-}
-
-func (sm *ServiceManager) StopAllServices() {
-	var stopServiceWG sync.WaitGroup
+	for _, dependent := range dependentsOrder(sm.services, serviceID) {
+		if dependent.GetStatus() == SERVICE_STOPPED {
+			continue
+		}
 
-	for serviceID := range sm.services {
-		stopServiceWG.Add(1)
+		log.Printf("stopping service '%s' (ID: '%s') because it depends on '%s'", dependent.Name, dependent.ID, service.Name)
 
-		go func(serviceID string) {
-			defer stopServiceWG.Done()
+		if _, err := dependent.Stop(gracePeriod); err != nil {
+			return false, fmt.Errorf("failed to stop dependent service '%s' (ID: '%s'). Error: %v", dependent.Name, dependent.ID, err)
+		}
+	}
 
-			sm.StopService(serviceID)
-		}(serviceID)
+	graceful, err := service.Stop(gracePeriod)
+	if err != nil {
+		return false, fmt.Errorf("failed to stop service '%s' (ID: '%s'). Error: %v", service.Name, service.ID, err)
 	}
 
-	stopServiceWG.Wait()
+	return graceful, nil
+}
+
+// StopAllServices stops every service in reverse dependency order.
+func (sm *ServiceManager) StopAllServices() {
+	sm.readWriteMutex.RLock()
+	defer sm.readWriteMutex.RUnlock()
+
+	stopInDependencyOrder(sm.services)
 }
 
-func NewServiceManager(logsDir, servicesDataPath string) *ServiceManager {
+// NewServiceManager creates a manager writing service logs under logsDir.
+// logRotation applies to every service unless overridden in its config.
+// Services with resource limits run in cgroups created in cgroupSlice.
+func NewServiceManager(logsDir, servicesDataPath string, logRotation logstore.RotationPolicy, cgroupSlice string) *ServiceManager {
 	return &ServiceManager{
-		services:         make(map[string]*service),
-		logsDir:          logsDir,
-		servicesDataPath: servicesDataPath,
+		services:          make(map[string]*service),
+		logsDir:           logsDir,
+		servicesDataPath:  servicesDataPath,
+		logRotation:       logRotation,
+		cgroupSlice:       cgroupSlice,
+		logHub:            logstore.NewHub(logstore.HUB_BUFFER_SIZE, logstore.HUB_BUFFER_BYTES),
+		statusBroadcaster: newStatusBroadcaster(),
+		startupSummary: StartupSummary{
+			Results: []AutostartResult{},
+		},
 	}
 }
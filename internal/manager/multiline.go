@@ -0,0 +1,129 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// How long a record waits for more lines before it is captured unless configured
+	DEFAULT_MULTILINE_FLUSH_TIMEOUT = 500 * time.Millisecond
+	// Maximum number of lines of a record unless configured
+	DEFAULT_MULTILINE_MAX_LINES = 500
+)
+
+// Lines continuing the current record for each preset. Stack traces have no
+// line of their own that starts a record, so presets match the lines that
+// belong to the record instead.
+var multilinePresets = map[MultilinePreset]*regexp.Regexp{
+	// Indented "at" frames, "... N more", "Caused by:" and exception lines
+	// following the log line that reports them
+	MULTILINE_JAVA: regexp.MustCompile(`^(\s+at\s|\s+\.\.\.\s\d+|\s*Caused by:|\s+Suppressed:|\s|[\w$.]+(Exception|Error|Throwable)(:|$))`),
+	// Tracebacks, their indented frames and source lines, chained exceptions
+	// and the final exception line
+	MULTILINE_PYTHON: regexp.MustCompile(`^(\s|$|Traceback \(most recent call last\):|During handling of the above exception|The above exception was the direct cause|[A-Za-z_][\w.]*(Error|Exception|Warning|Exit|Interrupt)(:|$))`),
+	// Goroutine headers, function calls and their indented file lines
+	MULTILINE_GO: regexp.MustCompile(`^(\s|$|goroutine \d+ \[|created by |runtime stack:|\[signal |exit status \d|\S+\(.*\)$)`),
+	// Indented "at" frames, the source excerpt of uncaught errors, error lines
+	// and the trailing Node.js version
+	MULTILINE_NODE: regexp.MustCompile(`^(\s|$|\w*Error(:|$| \[)|Node\.js v\d)`),
+}
+
+func (spec Multiline) validate() error {
+	if spec.Preset != "" && spec.StartPattern != "" {
+		return errors.New("preset and start pattern cannot be set together")
+	}
+
+	if spec.Preset != "" {
+		if _, ok := multilinePresets[spec.Preset]; !ok {
+			return fmt.Errorf("unknown multiline preset '%s'", spec.Preset)
+		}
+	}
+
+	if spec.StartPattern != "" {
+		if _, err := regexp.Compile(spec.StartPattern); err != nil {
+			return fmt.Errorf("invalid start pattern: %w", err)
+		}
+	}
+
+	if spec.FlushTimeoutSeconds < 0 {
+		return errors.New("flush timeout cannot be negative")
+	}
+
+	if spec.MaxLines < 0 {
+		return errors.New("max lines cannot be negative")
+	}
+
+	return nil
+}
+
+func (spec Multiline) enabled() bool {
+	return spec.Preset != "" || spec.StartPattern != ""
+}
+
+func (spec Multiline) flushTimeout() time.Duration {
+	if spec.FlushTimeoutSeconds == 0 {
+		return DEFAULT_MULTILINE_FLUSH_TIMEOUT
+	}
+	return secondsToDuration(spec.FlushTimeoutSeconds)
+}
+
+func (spec Multiline) maxLines() int {
+	if spec.MaxLines == 0 {
+		return DEFAULT_MULTILINE_MAX_LINES
+	}
+	return spec.MaxLines
+}
+
+// continues returns the function telling whether a line belongs to the
+// current record. The spec must be enabled and valid.
+func (spec Multiline) continues() func(line string) bool {
+	if spec.Preset != "" {
+		return multilinePresets[spec.Preset].MatchString
+	}
+
+	start := regexp.MustCompile(spec.StartPattern)
+	return func(line string) bool {
+		return !start.MatchString(line)
+	}
+}
+
+// multilineGrouper groups consecutive lines into records, which are passed on
+// with their lines separated by line breaks.
+type multilineGrouper struct {
+	continues func(line string) bool
+	maxLines  int
+	emit      func(record string)
+	lines     []string
+}
+
+func newMultilineGrouper(spec Multiline, emit func(record string)) *multilineGrouper {
+	return &multilineGrouper{
+		continues: spec.continues(),
+		maxLines:  spec.maxLines(),
+		emit:      emit,
+	}
+}
+
+// add appends a line to the current record, or passes the record on and
+// starts a new one with the line.
+func (g *multilineGrouper) add(line string) {
+	if len(g.lines) > 0 && (len(g.lines) >= g.maxLines || !g.continues(line)) {
+		g.flush()
+	}
+
+	g.lines = append(g.lines, line)
+}
+
+// flush passes the current record on, if any.
+func (g *multilineGrouper) flush() {
+	if len(g.lines) == 0 {
+		return
+	}
+
+	g.emit(strings.Join(g.lines, "\n"))
+	g.lines = g.lines[:0]
+}
@@ -0,0 +1,158 @@
+package manager
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func groupLines(spec Multiline, lines []string) []string {
+	var records []string
+	grouper := newMultilineGrouper(spec, func(record string) {
+		records = append(records, record)
+	})
+
+	for _, line := range lines {
+		grouper.add(line)
+	}
+	grouper.flush()
+
+	return records
+}
+
+func TestMultilineGrouper(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  Multiline
+		lines []string
+		want  []string
+	}{
+		{
+			name: "start pattern",
+			spec: Multiline{StartPattern: `^\d{4}-`},
+			lines: []string{
+				"2025-01-01 first",
+				"  detail",
+				"2025-01-01 second",
+				"2025-01-01 third",
+				"  more",
+				"  and more",
+			},
+			want: []string{
+				"2025-01-01 first\n  detail",
+				"2025-01-01 second",
+				"2025-01-01 third\n  more\n  and more",
+			},
+		},
+		{
+			name:  "lines before the first start",
+			spec:  Multiline{StartPattern: `^START`},
+			lines: []string{"orphan", "also orphan", "START record"},
+			want:  []string{"orphan\nalso orphan", "START record"},
+		},
+		{
+			name:  "max lines",
+			spec:  Multiline{StartPattern: `^START`, MaxLines: 2},
+			lines: []string{"START", "a", "b", "c", "d"},
+			want:  []string{"START\na", "b\nc", "d"},
+		},
+		{
+			name: "java",
+			spec: Multiline{Preset: MULTILINE_JAVA},
+			lines: []string{
+				"ERROR request failed",
+				"java.lang.IllegalStateException: boom",
+				"\tat com.example.Service.run(Service.java:10)",
+				"\t... 3 more",
+				"Caused by: java.io.IOException: closed",
+				"\tat com.example.Client.read(Client.java:20)",
+				"INFO next request",
+			},
+			want: []string{
+				"ERROR request failed\njava.lang.IllegalStateException: boom\n\tat com.example.Service.run(Service.java:10)\n\t... 3 more\nCaused by: java.io.IOException: closed\n\tat com.example.Client.read(Client.java:20)",
+				"INFO next request",
+			},
+		},
+		{
+			name: "python",
+			spec: Multiline{Preset: MULTILINE_PYTHON},
+			lines: []string{
+				"Traceback (most recent call last):",
+				`  File "app.py", line 3, in <module>`,
+				"    main()",
+				"ValueError: bad value",
+				"next line",
+			},
+			want: []string{
+				"Traceback (most recent call last):\n  File \"app.py\", line 3, in <module>\n    main()\nValueError: bad value",
+				"next line",
+			},
+		},
+		{
+			name: "go",
+			spec: Multiline{Preset: MULTILINE_GO},
+			lines: []string{
+				"panic: runtime error: index out of range",
+				"",
+				"goroutine 1 [running]:",
+				"main.main()",
+				"\t/app/main.go:5 +0x1d",
+				"exit status 2",
+				"restarting",
+			},
+			want: []string{
+				"panic: runtime error: index out of range\n\ngoroutine 1 [running]:\nmain.main()\n\t/app/main.go:5 +0x1d\nexit status 2",
+				"restarting",
+			},
+		},
+		{
+			name: "node",
+			spec: Multiline{Preset: MULTILINE_NODE},
+			lines: []string{
+				"/app/index.js:1",
+				"TypeError: x is not a function",
+				"    at Object.<anonymous> (/app/index.js:1:1)",
+				"Node.js v20.0.0",
+				"server listening",
+			},
+			want: []string{
+				"/app/index.js:1\nTypeError: x is not a function\n    at Object.<anonymous> (/app/index.js:1:1)\nNode.js v20.0.0",
+				"server listening",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := groupLines(test.spec, test.lines)
+			if !slices.Equal(got, test.want) {
+				t.Fatalf("records are\n%s\nwant\n%s", strings.Join(got, "\n--\n"), strings.Join(test.want, "\n--\n"))
+			}
+		})
+	}
+}
+
+func TestMultilineValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    Multiline
+		wantErr bool
+	}{
+		{name: "disabled", spec: Multiline{}},
+		{name: "preset", spec: Multiline{Preset: MULTILINE_PYTHON}},
+		{name: "start pattern", spec: Multiline{StartPattern: `^\S`}},
+		{name: "both", spec: Multiline{Preset: MULTILINE_GO, StartPattern: `^\S`}, wantErr: true},
+		{name: "unknown preset", spec: Multiline{Preset: "cobol"}, wantErr: true},
+		{name: "invalid pattern", spec: Multiline{StartPattern: `(`}, wantErr: true},
+		{name: "negative timeout", spec: Multiline{Preset: MULTILINE_GO, FlushTimeoutSeconds: -1}, wantErr: true},
+		{name: "negative max lines", spec: Multiline{Preset: MULTILINE_GO, MaxLines: -1}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.spec.validate(); (err != nil) != test.wantErr {
+				t.Errorf("validate() = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
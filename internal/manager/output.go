@@ -0,0 +1,175 @@
+package manager
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// Maximum length of a captured line unless configured, 64KB or roughly 65000 characters
+	DEFAULT_MAX_LINE_BYTES = 64 * 1024
+	// Highest configurable maximum length of a captured line
+	MAX_LINE_BYTES = 16 * 1024 * 1024
+	// Lowest configurable maximum length of a captured line
+	MIN_LINE_BYTES = 256
+	// Size of the buffer output is read through
+	OUTPUT_READ_BUFFER_SIZE = 64 * 1024
+	// Appended to every part of a split line but the last one
+	SPLIT_LINE_MARKER = " [service-manager] line continues"
+	// Appended to a truncated line, with the number of bytes dropped
+	TRUNCATED_LINE_MARKER = " [service-manager] %d bytes truncated"
+)
+
+func (spec LongLines) validate() error {
+	switch spec.Mode {
+	case "", LONG_LINES_SPLIT, LONG_LINES_TRUNCATE:
+	default:
+		return fmt.Errorf("unknown long line mode '%s'", spec.Mode)
+	}
+
+	if spec.MaxBytes != 0 && (spec.MaxBytes < MIN_LINE_BYTES || spec.MaxBytes > MAX_LINE_BYTES) {
+		return fmt.Errorf("max line bytes must be between %d and %d", MIN_LINE_BYTES, MAX_LINE_BYTES)
+	}
+
+	return nil
+}
+
+func (spec LongLines) maxBytes() int {
+	if spec.MaxBytes == 0 {
+		return DEFAULT_MAX_LINE_BYTES
+	}
+	return spec.MaxBytes
+}
+
+// streamOutput passes the lines read from the output of the process to
+// handler, grouped into records when the service has multiline rules.
+func (s *service) streamOutput(reader io.Reader, handler func(line string)) {
+	lines := make(chan string, 100)
+
+	s.mutex.Lock()
+	longLines := s.Config.LongLines
+	multiline := s.Config.Multiline
+	s.mutex.Unlock()
+
+	go func() {
+		defer close(lines)
+
+		err := readLines(reader, longLines, func(line string) {
+			lines <- line
+		})
+		if err != nil {
+			log.Printf("error reading: %v", err)
+		}
+	}()
+
+	if !multiline.enabled() {
+		for line := range lines {
+			handler(line)
+		}
+		return
+	}
+
+	grouper := newMultilineGrouper(multiline, handler)
+	defer grouper.flush()
+
+	flushTimer := time.NewTimer(multiline.flushTimeout())
+	flushTimer.Stop()
+	defer flushTimer.Stop()
+
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+
+			grouper.add(line)
+
+			// A record is captured once no line followed it for the timeout
+			flushTimer.Reset(multiline.flushTimeout())
+		case <-flushTimer.C:
+			grouper.flush()
+		}
+	}
+}
+
+// readLines reads the lines of reader until it is closed, passing on lines
+// longer than the maximum split or truncated with a marker. Only the line feed
+// ending a line is removed, a carriage return before it is part of the output.
+// The reader is always read to the end, so that a long line cannot leave the
+// writer blocked on a full pipe.
+func readLines(reader io.Reader, spec LongLines, emit func(line string)) error {
+	maxBytes := spec.maxBytes()
+	buffered := bufio.NewReaderSize(reader, OUTPUT_READ_BUFFER_SIZE)
+
+	var line []byte
+	// Number of bytes dropped from the end of the current line
+	truncated := 0
+
+	for {
+		// Lines longer than the buffer come in several fragments
+		fragment, err := buffered.ReadSlice('\n')
+		isPrefix := errors.Is(err, bufio.ErrBufferFull)
+		if err == nil {
+			fragment = fragment[:len(fragment)-1]
+		}
+
+		if truncated > 0 {
+			truncated += len(fragment)
+		} else {
+			line = append(line, fragment...)
+
+			for len(line) > maxBytes {
+				cut := runeBoundary(line, maxBytes)
+
+				if spec.Mode == LONG_LINES_TRUNCATE {
+					truncated = len(line) - cut
+					line = line[:cut]
+					break
+				}
+
+				emit(string(line[:cut]) + SPLIT_LINE_MARKER)
+				line = append(line[:0], line[cut:]...)
+			}
+		}
+
+		if isPrefix {
+			continue
+		}
+
+		// Output ending without a line feed still ends its last line
+		if err == nil || len(line) > 0 || truncated > 0 {
+			if truncated > 0 {
+				emit(string(line) + fmt.Sprintf(TRUNCATED_LINE_MARKER, truncated))
+			} else {
+				emit(string(line))
+			}
+		}
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		line = line[:0]
+		truncated = 0
+	}
+}
+
+// runeBoundary returns the highest index up to limit where line can be cut
+// without splitting a UTF-8 encoded character.
+func runeBoundary(line []byte, limit int) int {
+	for i := limit; i > 0 && i > limit-utf8.UTFMax; i-- {
+		if utf8.RuneStart(line[i]) {
+			return i
+		}
+	}
+	return limit
+}
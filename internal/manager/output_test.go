@@ -0,0 +1,138 @@
+package manager
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func collectLines(t *testing.T, input string, spec LongLines) []string {
+	t.Helper()
+
+	var lines []string
+	err := readLines(strings.NewReader(input), spec, func(line string) {
+		lines = append(lines, line)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+func TestReadLinesLongLines(t *testing.T) {
+	split := LongLines{Mode: LONG_LINES_SPLIT, MaxBytes: MIN_LINE_BYTES}
+	truncate := LongLines{Mode: LONG_LINES_TRUNCATE, MaxBytes: MIN_LINE_BYTES}
+
+	a := strings.Repeat("a", MIN_LINE_BYTES)
+	// "é" is 2 bytes, so the limit falls in the middle of one
+	accents := "x" + strings.Repeat("é", MIN_LINE_BYTES)
+
+	tests := []struct {
+		name  string
+		input string
+		spec  LongLines
+		want  []string
+	}{
+		{
+			name:  "short lines",
+			input: "one\ntwo\n",
+			spec:  split,
+			want:  []string{"one", "two"},
+		},
+		{
+			name:  "exactly the maximum",
+			input: a + "\n",
+			spec:  split,
+			want:  []string{a},
+		},
+		{
+			name:  "split",
+			input: a + a + "b\n",
+			spec:  split,
+			want:  []string{a + SPLIT_LINE_MARKER, a + SPLIT_LINE_MARKER, "b"},
+		},
+		{
+			name:  "truncated",
+			input: a + "bcd\nnext\n",
+			spec:  truncate,
+			want:  []string{a + fmt.Sprintf(TRUNCATED_LINE_MARKER, 3), "next"},
+		},
+		{
+			name:  "split at a rune boundary",
+			input: accents + "\n",
+			spec:  split,
+			// The rest of the line starts with a whole character
+			want: []string{
+				accents[:MIN_LINE_BYTES-1] + SPLIT_LINE_MARKER,
+				accents[MIN_LINE_BYTES-1:2*MIN_LINE_BYTES-1] + SPLIT_LINE_MARKER,
+				accents[2*MIN_LINE_BYTES-1:],
+			},
+		},
+		{
+			name:  "truncated at a rune boundary",
+			input: accents + "\n",
+			spec:  truncate,
+			want:  []string{accents[:MIN_LINE_BYTES-1] + fmt.Sprintf(TRUNCATED_LINE_MARKER, len(accents)-(MIN_LINE_BYTES-1))},
+		},
+		{
+			name:  "longer than the read buffer",
+			input: strings.Repeat("z", 3*OUTPUT_READ_BUFFER_SIZE+5) + "\nend\n",
+			spec:  truncate,
+			want:  []string{strings.Repeat("z", MIN_LINE_BYTES) + fmt.Sprintf(TRUNCATED_LINE_MARKER, 3*OUTPUT_READ_BUFFER_SIZE+5-MIN_LINE_BYTES), "end"},
+		},
+		{
+			name:  "default maximum",
+			input: strings.Repeat("d", DEFAULT_MAX_LINE_BYTES+1) + "\n",
+			spec:  LongLines{},
+			want:  []string{strings.Repeat("d", DEFAULT_MAX_LINE_BYTES) + SPLIT_LINE_MARKER, "d"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := collectLines(t, test.input, test.spec)
+			if !slices.Equal(got, test.want) {
+				t.Fatalf("got %d lines %.80q, want %d lines %.80q", len(got), got, len(test.want), test.want)
+			}
+
+			for _, line := range got {
+				if !utf8.ValidString(line) {
+					t.Fatalf("line %.80q was cut inside a character", line)
+				}
+			}
+		})
+	}
+}
+
+// Lines are passed on as written, only without the line feed ending them.
+func TestReadLinesKeepsBytes(t *testing.T) {
+	input := "  indented\t\n\x1b[31mred\x1b[0m\ncrlf\r\n10%\r100%\r\n\nlast without line feed"
+	want := []string{"  indented\t", "\x1b[31mred\x1b[0m", "crlf\r", "10%\r100%\r", "", "last without line feed"}
+
+	if got := collectLines(t, input, LongLines{}); !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestRuneBoundary(t *testing.T) {
+	tests := []struct {
+		line  string
+		limit int
+		want  int
+	}{
+		{line: "abcdef", limit: 3, want: 3},
+		{line: "ab€def", limit: 3, want: 2},
+		{line: "ab€def", limit: 4, want: 2},
+		{line: "ab€def", limit: 5, want: 5},
+		// Not UTF-8, cut at the limit
+		{line: "ab\x80\x80\x80\x80\x80def", limit: 6, want: 6},
+	}
+
+	for _, test := range tests {
+		if got := runeBoundary([]byte(test.line), test.limit); got != test.want {
+			t.Errorf("runeBoundary(%q, %d) = %d, want %d", test.line, test.limit, got, test.want)
+		}
+	}
+}
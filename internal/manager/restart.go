@@ -0,0 +1,164 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+const (
+	DEFAULT_RESTART_INITIAL_DELAY = 1 * time.Second
+	DEFAULT_RESTART_MULTIPLIER    = 2.0
+	DEFAULT_RESTART_MAX_DELAY     = 60 * time.Second
+)
+
+func (p RestartPolicy) validate() error {
+	switch p.Mode {
+	case "", RESTART_NEVER, RESTART_ON_FAILURE, RESTART_ALWAYS:
+	default:
+		return fmt.Errorf("unknown restart mode '%s'", p.Mode)
+	}
+
+	if p.MaxRetries < 0 {
+		return errors.New("max retries cannot be negative")
+	}
+
+	if p.InitialDelaySeconds < 0 || p.MaxDelaySeconds < 0 {
+		return errors.New("restart delays cannot be negative")
+	}
+
+	if p.Multiplier != 0 && p.Multiplier < 1 {
+		return errors.New("restart multiplier must be at least 1")
+	}
+
+	return nil
+}
+
+func (p RestartPolicy) shouldRestart(exitErr error) bool {
+	switch p.Mode {
+	case RESTART_ALWAYS:
+		return true
+	case RESTART_ON_FAILURE:
+		return exitErr != nil
+	default:
+		return false
+	}
+}
+
+func (p RestartPolicy) maxDelay() time.Duration {
+	if p.MaxDelaySeconds == 0 {
+		return DEFAULT_RESTART_MAX_DELAY
+	}
+	return secondsToDuration(p.MaxDelaySeconds)
+}
+
+// backoff returns the delay before the given restart attempt (starting at 1).
+func (p RestartPolicy) backoff(attempt int) time.Duration {
+	initialDelay := DEFAULT_RESTART_INITIAL_DELAY
+	if p.InitialDelaySeconds != 0 {
+		initialDelay = secondsToDuration(p.InitialDelaySeconds)
+	}
+
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = DEFAULT_RESTART_MULTIPLIER
+	}
+
+	delay := float64(initialDelay) * math.Pow(multiplier, float64(attempt-1))
+	if delay > float64(p.maxDelay()) {
+		return p.maxDelay()
+	}
+
+	return time.Duration(delay)
+}
+
+func (s *service) GetRestartState() RestartState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return RestartState{
+		Attempts:    s.restartAttempts,
+		NextRetryAt: s.nextRetryAt,
+	}
+}
+
+func (s *service) scheduleRestart(attempt int, delay time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.changeStatus(SERVICE_RESTARTING)
+	s.restartAttempts = attempt
+	s.nextRetryAt = time.Now().Add(delay)
+}
+
+func (s *service) markStopped() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.changeStatus(SERVICE_STOPPED)
+	s.nextRetryAt = time.Time{}
+}
+
+// supervise runs the command of the service and restarts it according to its
+// restart policy until the service context is cancelled. started receives the
+// outcome of spawning the first process.
+func (s *service) supervise(ctx context.Context, started chan<- error) {
+	defer s.commandWaitGroup.Done()
+	defer s.markStopped()
+
+	policy := s.Config.RestartPolicy
+
+	for {
+		runStart := time.Now()
+
+		err := s.executeCommand(ctx, started)
+
+		// A service that cannot be spawned at all is not restarted, Start
+		// returns the error instead
+		if started != nil && errors.Is(err, errStartFailed) {
+			return
+		}
+		started = nil
+
+		// Cancelled context means the service was stopped through the manager
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			log.Printf("service '%s' (ID: '%s') exited: %v", s.Name, s.ID, err)
+		}
+
+		// Restarting an unhealthy service was requested by its health check,
+		// whatever the restart policy says
+		if !policy.shouldRestart(err) && !errors.Is(err, errUnhealthy) {
+			return
+		}
+
+		attempt := s.GetRestartState().Attempts + 1
+
+		// A run that lasted longer than the backoff cap is considered stable,
+		// so the next crash starts backing off from the beginning again
+		if time.Since(runStart) >= policy.maxDelay() {
+			attempt = 1
+		}
+
+		if policy.MaxRetries > 0 && attempt > policy.MaxRetries {
+			log.Printf("service '%s' (ID: '%s') reached max restart retries (%d), giving up", s.Name, s.ID, policy.MaxRetries)
+			return
+		}
+
+		delay := policy.backoff(attempt)
+		s.scheduleRestart(attempt, delay)
+
+		log.Printf("restarting service '%s' (ID: '%s') in %v (attempt %d)", s.Name, s.ID, delay, attempt)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,130 @@
+//go:build linux
+
+package manager
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"service-manager/internal/logstore"
+)
+
+// A crashing service must be restarted and watched like its first run, so
+// that it keeps being restarted and can be stopped.
+func TestCrashingServiceRestartsAndStops(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewServiceManager(filepath.Join(dir, "logs"), filepath.Join(dir, "services.json"), logstore.RotationPolicy{}, "")
+
+	config := ServiceConfig{
+		RestartPolicy: RestartPolicy{Mode: RESTART_ON_FAILURE, InitialDelaySeconds: 0.05, Multiplier: 1},
+	}
+	if err := sm.RegisterService("crash", "sh", []string{"-c", "exit 3"}, dir, config); err != nil {
+		t.Fatalf("register service: %v", err)
+	}
+
+	service := sm.GetAllServices()[0]
+	if err := sm.StartService(service.ID); err != nil {
+		t.Fatalf("start service: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(service.GetRuns()) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("service ran %d times, want at least 3", len(service.GetRuns()))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	stopped := make(chan error, 1)
+	go func() {
+		_, err := sm.StopService(service.ID, nil)
+		stopped <- err
+	}()
+
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatalf("stop service: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stopping the restarted service did not return")
+	}
+
+	if status := service.GetStatus(); status != SERVICE_STOPPED {
+		t.Fatalf("status is %s after stop, want %s", status, SERVICE_STOPPED)
+	}
+}
+
+func TestRestartPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RestartPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{name: "defaults, first attempt", policy: RestartPolicy{}, attempt: 1, want: time.Second},
+		{name: "defaults, doubled", policy: RestartPolicy{}, attempt: 3, want: 4 * time.Second},
+		{name: "defaults, capped", policy: RestartPolicy{}, attempt: 10, want: DEFAULT_RESTART_MAX_DELAY},
+		{name: "initial delay", policy: RestartPolicy{InitialDelaySeconds: 0.5}, attempt: 1, want: 500 * time.Millisecond},
+		{name: "multiplier", policy: RestartPolicy{InitialDelaySeconds: 1, Multiplier: 3}, attempt: 3, want: 9 * time.Second},
+		{name: "constant", policy: RestartPolicy{InitialDelaySeconds: 2, Multiplier: 1}, attempt: 5, want: 2 * time.Second},
+		{name: "max delay", policy: RestartPolicy{InitialDelaySeconds: 1, MaxDelaySeconds: 5}, attempt: 4, want: 5 * time.Second},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.policy.backoff(test.attempt); got != test.want {
+				t.Errorf("backoff(%d) = %v, want %v", test.attempt, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRestartPolicyShouldRestart(t *testing.T) {
+	exitErr := errors.New("exit status 1")
+
+	tests := []struct {
+		mode    RestartMode
+		exitErr error
+		want    bool
+	}{
+		{mode: "", exitErr: exitErr, want: false},
+		{mode: RESTART_NEVER, exitErr: exitErr, want: false},
+		{mode: RESTART_ON_FAILURE, exitErr: nil, want: false},
+		{mode: RESTART_ON_FAILURE, exitErr: exitErr, want: true},
+		{mode: RESTART_ALWAYS, exitErr: nil, want: true},
+		{mode: RESTART_ALWAYS, exitErr: exitErr, want: true},
+	}
+
+	for _, test := range tests {
+		policy := RestartPolicy{Mode: test.mode}
+		if got := policy.shouldRestart(test.exitErr); got != test.want {
+			t.Errorf("mode '%s' with exit error %v: shouldRestart = %v, want %v", test.mode, test.exitErr, got, test.want)
+		}
+	}
+}
+
+func TestRestartPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RestartPolicy
+		wantErr bool
+	}{
+		{name: "zero", policy: RestartPolicy{}},
+		{name: "on failure", policy: RestartPolicy{Mode: RESTART_ON_FAILURE, MaxRetries: 3, Multiplier: 1.5}},
+		{name: "unknown mode", policy: RestartPolicy{Mode: "sometimes"}, wantErr: true},
+		{name: "negative retries", policy: RestartPolicy{MaxRetries: -1}, wantErr: true},
+		{name: "negative delay", policy: RestartPolicy{InitialDelaySeconds: -1}, wantErr: true},
+		{name: "multiplier below 1", policy: RestartPolicy{Multiplier: 0.5}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.policy.validate(); (err != nil) != test.wantErr {
+				t.Errorf("validate() = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package manager
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// Number of runs kept in the history of a service
+	RUN_HISTORY_SIZE = 20
+	// Number of stderr lines kept in a run record
+	STDERR_TAIL_LINES = 20
+	// How long to wait for the output pipes to be drained once the process exited.
+	// Processes left in the group may keep them open forever.
+	OUTPUT_DRAIN_TIMEOUT = 2 * time.Second
+)
+
+// errStartFailed wraps the errors that prevent the process from being spawned
+var errStartFailed = errors.New("start failed")
+
+// errOOMKilled ends a run whose process was killed for going over its memory limit
+var errOOMKilled = errors.New("killed for going over the memory limit")
+
+// lineTail keeps the last lines written to it.
+type lineTail struct {
+	lines []string
+	size  int
+	mutex sync.Mutex
+}
+
+func newLineTail(size int) *lineTail {
+	return &lineTail{
+		lines: make([]string, 0, size),
+		size:  size,
+	}
+}
+
+func (t *lineTail) add(line string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if len(t.lines) == t.size {
+		t.lines = append(t.lines[:0], t.lines[1:]...)
+	}
+	t.lines = append(t.lines, line)
+}
+
+func (t *lineTail) snapshot() []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]string{}, t.lines...)
+}
+
+// waitTimeout waits for the wait group and returns false if it took longer than timeout.
+func waitTimeout(waitGroup *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		waitGroup.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// recordRun completes the run record from the exit state of the process and
+// appends it to the bounded history of the service.
+func (s *service) recordRun(run RunRecord, state *os.ProcessState, runErr error, stopped bool) {
+	run.EndTime = time.Now()
+
+	if state != nil {
+		if exitCode := state.ExitCode(); exitCode >= 0 {
+			run.ExitCode = &exitCode
+		}
+		run.Signal = exitSignal(state)
+	}
+
+	switch {
+	case stopped:
+		run.Reason = EXIT_USER_STOP
+	case errors.Is(runErr, errUnhealthy):
+		run.Reason = EXIT_UNHEALTHY
+	case errors.Is(runErr, errOOMKilled):
+		run.Reason = EXIT_OOM_KILLED
+	case state == nil:
+		run.Reason = EXIT_START_FAILED
+	case state.Success():
+		run.Reason = EXIT_COMPLETED
+	default:
+		run.Reason = EXIT_CRASH
+	}
+
+	if runErr != nil && !stopped {
+		run.Error = runErr.Error()
+	}
+
+	log.Printf("service '%s' (ID: '%s') run ended: reason=%s exit_code=%v signal=%s", s.Name, s.ID, run.Reason, formatExitCode(run.ExitCode), run.Signal)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.runs) == RUN_HISTORY_SIZE {
+		s.runs = append(s.runs[:0], s.runs[1:]...)
+	}
+	s.runs = append(s.runs, run)
+}
+
+// GetRuns returns the recorded runs of the service, most recent first.
+func (s *service) GetRuns() []RunRecord {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	runs := make([]RunRecord, 0, len(s.runs))
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		runs = append(runs, s.runs[i])
+	}
+
+	return runs
+}
+
+func formatExitCode(exitCode *int) any {
+	if exitCode == nil {
+		return "none"
+	}
+	return *exitCode
+}
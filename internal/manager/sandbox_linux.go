@@ -0,0 +1,159 @@
+//go:build linux
+
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/shirou/gopsutil/v3/process"
+	"golang.org/x/sys/unix"
+)
+
+func (sandbox Sandbox) validate() error {
+	for _, path := range sandbox.ReadOnlyPaths {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("read-only path '%s' is not absolute", path)
+		}
+	}
+
+	return nil
+}
+
+// check reports whether the manager can create the namespaces of the sandbox.
+func (sandbox Sandbox) check() error {
+	if sandbox.cloneflags() != 0 && os.Geteuid() != 0 {
+		return errors.New("namespaces require the manager to run as root")
+	}
+
+	return nil
+}
+
+func (sandbox Sandbox) enabled() bool {
+	return sandbox.cloneflags() != 0 || sandbox.NoNewPrivileges
+}
+
+// cloneflags returns the namespaces the service is started in.
+func (sandbox Sandbox) cloneflags() uintptr {
+	var flags uintptr
+
+	// A PID namespace needs a mount namespace to mount its own /proc
+	if len(sandbox.ReadOnlyPaths) > 0 || sandbox.PrivatePID {
+		flags |= syscall.CLONE_NEWNS
+	}
+	if sandbox.PrivatePID {
+		flags |= syscall.CLONE_NEWPID
+	}
+	if sandbox.NoNetwork {
+		flags |= syscall.CLONE_NEWNET
+	}
+
+	return flags
+}
+
+// setUp configures the namespaces of the launcher, which runs as root in them,
+// before the command is executed.
+func (sandbox Sandbox) setUp() error {
+	if sandbox.cloneflags()&syscall.CLONE_NEWNS != 0 {
+		// Mounts made in the namespace must not propagate to the host
+		if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+			return fmt.Errorf("make mounts private: %w", err)
+		}
+	}
+
+	if sandbox.PrivatePID {
+		err := unix.Mount("proc", "/proc", "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, "")
+		if err != nil {
+			return fmt.Errorf("mount /proc: %w", err)
+		}
+	}
+
+	for _, path := range sandbox.ReadOnlyPaths {
+		if err := mountReadOnly(path); err != nil {
+			return fmt.Errorf("mount %s read-only: %w", path, err)
+		}
+	}
+
+	if sandbox.NoNetwork {
+		if err := loopbackUp(); err != nil {
+			return fmt.Errorf("bring loopback up: %w", err)
+		}
+	}
+
+	if sandbox.NoNewPrivileges {
+		if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+			return fmt.Errorf("set no new privileges: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// mountReadOnly bind mounts a path on itself and makes it and the mounts below
+// it read-only.
+func mountReadOnly(path string) error {
+	if err := unix.Mount(path, path, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return err
+	}
+
+	err := unix.MountSetattr(-1, path, unix.AT_RECURSIVE, &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY})
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, unix.ENOSYS) {
+		return err
+	}
+
+	// Before Linux 5.12 only the mount at the path can be made read-only. A
+	// remount resets the flags it does not set, which statfs reports with the
+	// values of the mount flags.
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return err
+	}
+	kept := uintptr(stat.Flags) & (unix.MS_NOSUID | unix.MS_NODEV | unix.MS_NOEXEC | unix.MS_NOATIME | unix.MS_NODIRATIME | unix.MS_RELATIME)
+
+	return unix.Mount("", path, "", unix.MS_BIND|unix.MS_REMOUNT|unix.MS_RDONLY|kept, "")
+}
+
+// loopbackUp brings up the loopback interface of a new network namespace,
+// which is down, so the service can still reach itself on localhost.
+func loopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	ifreq, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifreq); err != nil {
+		return err
+	}
+	ifreq.SetUint16(ifreq.Uint16() | unix.IFF_UP)
+
+	return unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifreq)
+}
+
+// mainProcess returns the process running the command of the service. In a
+// PID namespace it is the child of the launcher, which runs as its init.
+func (s *service) mainProcess() (*process.Process, error) {
+	proc, err := process.NewProcess(int32(s.pid))
+	if err != nil || !s.Config.Sandbox.PrivatePID {
+		return proc, err
+	}
+
+	children, err := proc.Children()
+	if err != nil || len(children) == 0 {
+		// The command is not started yet or has exited
+		return proc, nil
+	}
+
+	return children[0], nil
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package manager
+
+import "errors"
+
+func (sandbox Sandbox) validate() error {
+	if len(sandbox.ReadOnlyPaths) > 0 || sandbox.PrivatePID || sandbox.NoNetwork || sandbox.NoNewPrivileges {
+		return errors.New("sandboxing is only supported on Linux")
+	}
+
+	return nil
+}
+
+func (sandbox Sandbox) check() error {
+	return nil
+}
@@ -0,0 +1,225 @@
+//go:build linux
+
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Value of a limit without a limit
+	RLIMIT_UNLIMITED = -1
+	// Priority within the realtime and best-effort IO classes unless configured
+	DEFAULT_IO_PRIORITY = 4
+	// Position of the class in an IO priority, below it is the priority within the class
+	IOPRIO_CLASS_SHIFT = 13
+	// ioprio_set applies to a process, or to the calling thread when it is 0
+	IOPRIO_WHO_PROCESS = 1
+	// Number of CPUs a unix.CPUSet holds
+	CPU_SETSIZE = 1024
+)
+
+var rlimitResources = map[string]int{
+	"as":         unix.RLIMIT_AS,
+	"core":       unix.RLIMIT_CORE,
+	"cpu":        unix.RLIMIT_CPU,
+	"data":       unix.RLIMIT_DATA,
+	"fsize":      unix.RLIMIT_FSIZE,
+	"locks":      unix.RLIMIT_LOCKS,
+	"memlock":    unix.RLIMIT_MEMLOCK,
+	"msgqueue":   unix.RLIMIT_MSGQUEUE,
+	"nice":       unix.RLIMIT_NICE,
+	"nofile":     unix.RLIMIT_NOFILE,
+	"nproc":      unix.RLIMIT_NPROC,
+	"rss":        unix.RLIMIT_RSS,
+	"rtprio":     unix.RLIMIT_RTPRIO,
+	"rttime":     unix.RLIMIT_RTTIME,
+	"sigpending": unix.RLIMIT_SIGPENDING,
+	"stack":      unix.RLIMIT_STACK,
+}
+
+var ioClasses = map[IOClass]int{
+	IO_CLASS_REALTIME:    1,
+	IO_CLASS_BEST_EFFORT: 2,
+	IO_CLASS_IDLE:        3,
+}
+
+func validateRlimits(rlimits map[string]Rlimit) error {
+	for name, rlimit := range rlimits {
+		if _, ok := rlimitResource(name); !ok {
+			return fmt.Errorf("unknown rlimit '%s'", name)
+		}
+
+		soft, hard := rlimit.values()
+		if soft < RLIMIT_UNLIMITED || hard < RLIMIT_UNLIMITED {
+			return fmt.Errorf("rlimit '%s' cannot be below -1", name)
+		}
+		if hard != RLIMIT_UNLIMITED && (soft == RLIMIT_UNLIMITED || soft > hard) {
+			return fmt.Errorf("soft rlimit '%s' cannot be above the hard limit", name)
+		}
+	}
+
+	return nil
+}
+
+// checkRlimits reports whether the manager can set the limits. Without root
+// the hard limits can only be lowered.
+func checkRlimits(rlimits map[string]Rlimit) error {
+	if os.Geteuid() == 0 {
+		return nil
+	}
+
+	for name, rlimit := range rlimits {
+		_, hard := rlimit.values()
+
+		var current syscall.Rlimit
+		resource, _ := rlimitResource(name)
+		if syscall.Getrlimit(resource, &current) == nil && rlimitValue(hard) > current.Max {
+			return fmt.Errorf("raising hard rlimit '%s' requires the manager to run as root", name)
+		}
+	}
+
+	return nil
+}
+
+// rlimitResource returns the resource of a limit named like "nofile" or
+// "RLIMIT_NOFILE".
+func rlimitResource(name string) (int, bool) {
+	resource, ok := rlimitResources[strings.TrimPrefix(strings.ToLower(name), "rlimit_")]
+	return resource, ok
+}
+
+// values returns the soft and hard limits, the hard limit defaulting to the
+// soft one.
+func (rlimit Rlimit) values() (int64, int64) {
+	if rlimit.Hard == nil {
+		return rlimit.Soft, rlimit.Soft
+	}
+	return rlimit.Soft, *rlimit.Hard
+}
+
+func (scheduling Scheduling) validate() error {
+	if scheduling.Nice != nil && (*scheduling.Nice < -20 || *scheduling.Nice > 19) {
+		return errors.New("nice must be between -20 and 19")
+	}
+
+	if scheduling.IOClass != "" {
+		if _, ok := ioClasses[scheduling.IOClass]; !ok {
+			return fmt.Errorf("unknown io class '%s'", scheduling.IOClass)
+		}
+	}
+
+	if scheduling.IOPriority != nil {
+		if scheduling.IOClass != IO_CLASS_REALTIME && scheduling.IOClass != IO_CLASS_BEST_EFFORT {
+			return errors.New("io priority requires the realtime or best-effort io class")
+		}
+		if *scheduling.IOPriority < 0 || *scheduling.IOPriority > 7 {
+			return errors.New("io priority must be between 0 and 7")
+		}
+	}
+
+	for _, cpu := range scheduling.CPUAffinity {
+		if cpu < 0 || cpu >= CPU_SETSIZE {
+			return fmt.Errorf("invalid cpu %d in cpu affinity", cpu)
+		}
+	}
+
+	return nil
+}
+
+// check reports whether the manager can set the priorities.
+func (scheduling Scheduling) check() error {
+	if os.Geteuid() == 0 {
+		return nil
+	}
+
+	if scheduling.Nice != nil && *scheduling.Nice < 0 {
+		return errors.New("a negative nice requires the manager to run as root")
+	}
+	if scheduling.IOClass == IO_CLASS_REALTIME {
+		return errors.New("the realtime io class requires the manager to run as root")
+	}
+
+	return nil
+}
+
+func (scheduling Scheduling) enabled() bool {
+	return scheduling.Nice != nil || scheduling.IOClass != "" || len(scheduling.CPUAffinity) > 0
+}
+
+// applyRlimits sets the limits of the launcher, which the command inherits.
+func applyRlimits(rlimits map[string]Rlimit) error {
+	// Applied in a fixed order, so a failure is always reported on the same limit
+	names := make([]string, 0, len(rlimits))
+	for name := range rlimits {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		soft, hard := rlimits[name].values()
+
+		// syscall.Setrlimit also keeps the Go runtime from restoring the
+		// original open files limit when the command is executed
+		limit := syscall.Rlimit{Cur: rlimitValue(soft), Max: rlimitValue(hard)}
+		resource, _ := rlimitResource(name)
+		if err := syscall.Setrlimit(resource, &limit); err != nil {
+			return fmt.Errorf("set rlimit '%s': %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func rlimitValue(value int64) uint64 {
+	if value == RLIMIT_UNLIMITED {
+		return unix.RLIM_INFINITY
+	}
+	return uint64(value)
+}
+
+// apply sets the priority of the calling thread, which must be the one
+// executing the command: Linux keeps the nice value, IO priority and CPU
+// affinity per thread.
+func (scheduling Scheduling) apply() error {
+	if scheduling.Nice != nil {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, 0, *scheduling.Nice); err != nil {
+			return fmt.Errorf("set nice: %w", err)
+		}
+	}
+
+	if scheduling.IOClass != "" {
+		priority := DEFAULT_IO_PRIORITY
+		if scheduling.IOPriority != nil {
+			priority = *scheduling.IOPriority
+		}
+		if scheduling.IOClass == IO_CLASS_IDLE {
+			priority = 0
+		}
+
+		ioprio := ioClasses[scheduling.IOClass]<<IOPRIO_CLASS_SHIFT | priority
+		_, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, IOPRIO_WHO_PROCESS, 0, uintptr(ioprio))
+		if errno != 0 {
+			return fmt.Errorf("set io priority: %w", errno)
+		}
+	}
+
+	if len(scheduling.CPUAffinity) > 0 {
+		var cpus unix.CPUSet
+		for _, cpu := range scheduling.CPUAffinity {
+			cpus.Set(cpu)
+		}
+		if err := unix.SchedSetaffinity(0, &cpus); err != nil {
+			return fmt.Errorf("set cpu affinity: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+//go:build windows
+
+package manager
+
+import "errors"
+
+func validateRlimits(rlimits map[string]Rlimit) error {
+	if len(rlimits) > 0 {
+		return errors.New("rlimits are only supported on Linux")
+	}
+
+	return nil
+}
+
+func checkRlimits(rlimits map[string]Rlimit) error {
+	return nil
+}
+
+func (scheduling Scheduling) validate() error {
+	if scheduling.Nice != nil || scheduling.IOClass != "" || scheduling.IOPriority != nil || len(scheduling.CPUAffinity) > 0 {
+		return errors.New("scheduling is only supported on Linux")
+	}
+
+	return nil
+}
+
+func (scheduling Scheduling) check() error {
+	return nil
+}
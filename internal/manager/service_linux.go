@@ -3,14 +3,16 @@
 package manager
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"os"
 	"os/exec"
+	"service-manager/internal/logstore"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -25,48 +27,33 @@ type service struct {
 	Name             string
 	Cmd              Command
 	ExecuteDirectory string
+	Config           ServiceConfig
 	pid              int
 	startTime        time.Time
 	status           ServiceStatus
-	stdoutHandler    func(service *service, line string)
-	stderrHandler    func(service *service, line string)
+	restartAttempts  int
+	nextRetryAt      time.Time
+	stopGracePeriod  *time.Duration
+	lastRunKilled    bool
+	runs             []RunRecord
+	health           HealthState
+	// Numbers the log entries of all streams, created when the logs are first opened
+	logSequence   *logstore.Sequence
+	openLog       func(service *service, streamName string) (logSink, error)
+	statusChanged func(change StatusChange)
+	// Cgroup v2 slice the runs are placed in, relative to the cgroup mount
+	cgroupSlice string
+	// Terminal side of the manager of the current run, when it runs in a terminal
+	tty              *os.File
 	cancelService    context.CancelFunc
 	mutex            sync.Mutex
 	commandWaitGroup sync.WaitGroup
 }
 
-func (s *service) streamOutput(reader io.ReadCloser, handler func(service *service, line string)) {
-	lines := make(chan string, 100)
-
-	// 64KB per lines, or roughly 65000 characters per line
-	const SCANNER_MAX_CAPACITY = 64 * 1024
-
-	go func() {
-		defer close(lines)
-
-		scanner := bufio.NewScanner(reader)
-
-		buf := make([]byte, SCANNER_MAX_CAPACITY)
-		scanner.Buffer(buf, SCANNER_MAX_CAPACITY)
-
-		for scanner.Scan() {
-			lines <- scanner.Text()
-		}
-
-		if err := scanner.Err(); err != nil {
-			log.Printf("error reading: %v", err)
-		}
-	}()
-
-	for line := range lines {
-		handler(s, line)
-	}
-}
-
 func (s *service) setStatus(status ServiceStatus) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	s.status = status
+	s.changeStatus(status)
 }
 
 func (s *service) GetStatus() ServiceStatus {
@@ -76,7 +63,7 @@ func (s *service) GetStatus() ServiceStatus {
 }
 
 func (s *service) GetResourcesUsage() ResourcesData {
-	proc, err := process.NewProcess(int32(s.pid))
+	proc, err := s.mainProcess()
 	if err != nil {
 		return ResourcesData{
 			CPUPercent: 0.0,
@@ -123,50 +110,223 @@ func (s *service) GetNetworkInfo() []NetworkInfo {
 	return res
 }
 
-func (s *service) executeCommand(ctx context.Context) error {
-	defer s.commandWaitGroup.Done()
-
+// executeCommand runs the process once. started receives the outcome of spawning
+// the process when it is not nil.
+func (s *service) executeCommand(ctx context.Context, started chan<- error) (err error) {
 	cmd := exec.Command(s.Cmd.Name, s.Cmd.Arguments...)
 	cmd.Dir = s.ExecuteDirectory
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		Setpgid: true,
 	}
 
-	s.setStatus(SERVICE_RUNNING)
+	s.setStatus(s.Config.HealthCheck.initialStatus())
 	s.startTime = time.Now()
 
+	// The run context is cancelled to restart an unhealthy process without
+	// stopping the service
+	runCtx, cancelRun := context.WithCancel(ctx)
+	defer cancelRun()
+
+	var unhealthy atomic.Bool
+
+	run := RunRecord{StartTime: s.startTime}
+	stderrTail := newLineTail(STDERR_TAIL_LINES)
+
 	defer func() {
-		s.setStatus(SERVICE_STOPPED)
 		var zeroTime time.Time
 		s.startTime = zeroTime
+
+		run.StderrTail = stderrTail.snapshot()
+		s.recordRun(run, cmd.ProcessState, err, ctx.Err() != nil)
+
+		if started != nil {
+			started <- err
+		}
 	}()
 
-	outReader, err := cmd.StdoutPipe()
+	cmd.Env, err = s.buildEnv()
 	if err != nil {
-		return fmt.Errorf("create stdout pipe: %w", err)
+		return fmt.Errorf("%w: build environment: %v", errStartFailed, err)
 	}
 
-	errReader, err := cmd.StderrPipe()
+	// Users and groups may have changed since the service was registered
+	cmd.SysProcAttr.Credential, err = s.Config.credential()
 	if err != nil {
-		return fmt.Errorf("create stderr pipe: %w", err)
+		return fmt.Errorf("%w: resolve user: %v", errStartFailed, err)
 	}
 
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("start command: %w", err)
+	cmd.SysProcAttr.Cloneflags = s.Config.Sandbox.cloneflags()
+
+	umask, _ := s.Config.umask()
+	if umask != nil || s.Config.Sandbox.enabled() || len(s.Config.Rlimits) > 0 || s.Config.Scheduling.enabled() {
+		spec := launchSpec{
+			Umask:      umask,
+			Credential: cmd.SysProcAttr.Credential,
+			Sandbox:    s.Config.Sandbox,
+			Rlimits:    s.Config.Rlimits,
+			Scheduling: s.Config.Scheduling,
+		}
+		// Raising limits and priorities and setting namespaces up may need the
+		// privileges of the manager, the launcher switches user afterwards
+		cmd.SysProcAttr.Credential = nil
+
+		if err := useLauncher(cmd, spec); err != nil {
+			return fmt.Errorf("%w: %v", errStartFailed, err)
+		}
+	}
+
+	stdoutLog, err := s.openLog(s, "stdout")
+	if err != nil {
+		return fmt.Errorf("%w: open stdout log: %v", errStartFailed, err)
+	}
+	defer stdoutLog.Close()
+
+	stderrLog, err := s.openLog(s, "stderr")
+	if err != nil {
+		return fmt.Errorf("%w: open stderr log: %v", errStartFailed, err)
+	}
+	defer stderrLog.Close()
+
+	// The files of the command are closed once it is started, as it has its
+	// own copy
+	var commandFiles []*os.File
+	closeCommandFiles := func() {
+		for _, file := range commandFiles {
+			file.Close()
+		}
+	}
+
+	var outReader, errReader io.Reader
+	if s.Config.TTY {
+		terminal, commandTerminal, err := openTTY(cmd, s.Config.TTYSize)
+		if err != nil {
+			return fmt.Errorf("%w: open terminal: %v", errStartFailed, err)
+		}
+		defer terminal.Close()
+		commandFiles = append(commandFiles, commandTerminal)
+		outReader = ttyReader{terminal}
+
+		s.setTTY(terminal)
+		defer s.setTTY(nil)
+	} else {
+		// Pipes are created by hand instead of with cmd.StdoutPipe, so that cmd.Wait
+		// does not close them before all the output has been read
+		outPipe, outWriter, err := os.Pipe()
+		if err != nil {
+			return fmt.Errorf("%w: create stdout pipe: %v", errStartFailed, err)
+		}
+		defer outPipe.Close()
+		commandFiles = append(commandFiles, outWriter)
+
+		errPipe, errWriter, err := os.Pipe()
+		if err != nil {
+			closeCommandFiles()
+			return fmt.Errorf("%w: create stderr pipe: %v", errStartFailed, err)
+		}
+		defer errPipe.Close()
+		commandFiles = append(commandFiles, errWriter)
+
+		cmd.Stdout = outWriter
+		cmd.Stderr = errWriter
+		outReader, errReader = outPipe, errPipe
+	}
+
+	var cgroup *serviceCgroup
+	if s.Config.Resources != (ResourceLimits{}) {
+		cgroup, err = newServiceCgroup(s.cgroupSlice, s.ID, s.Config.Resources)
+		if err != nil {
+			closeCommandFiles()
+			return fmt.Errorf("%w: create cgroup: %v", errStartFailed, err)
+		}
+		defer cgroup.remove()
+
+		// The process is created in the cgroup, before it can fork
+		cmd.SysProcAttr.UseCgroupFD = true
+		cmd.SysProcAttr.CgroupFD = cgroup.fd()
+	}
+
+	err = cmd.Start()
+
+	closeCommandFiles()
+
+	if err != nil {
+		return fmt.Errorf("%w: start command: %v", errStartFailed, err)
+	}
+
+	// Only the first run of a start reports that it was spawned
+	if started != nil {
+		started <- nil
+		started = nil
 	}
 
 	// Save pid to monitor resources usage
 	s.pid = cmd.Process.Pid
 
-	go killProcessOnCancel(ctx, s.Name, cmd.Process.Pid)
+	exited := make(chan struct{})
+	killed := make(chan bool, 1)
+	go func() {
+		killed <- killProcessOnCancel(runCtx, s.Name, cmd.Process.Pid, exited, s.stopParams)
+	}()
 
-	go s.streamOutput(outReader, s.stdoutHandler)
-	go s.streamOutput(errReader, s.stderrHandler)
+	healthDone := make(chan struct{})
+	go func() {
+		defer close(healthDone)
+		s.monitorHealth(runCtx, func() {
+			unhealthy.Store(true)
+			cancelRun()
+		})
+	}()
+
+	var streamWaitGroup sync.WaitGroup
+
+	stdoutHandler := stdoutLog.WriteLine
+	if s.Config.TTY {
+		// The terminal carries both streams, its output is logged as stdout
+		// and also kept as the stderr tail of the run
+		stdoutHandler = func(line string) {
+			stderrTail.add(line)
+			stdoutLog.WriteLine(line)
+		}
+	}
+
+	streamWaitGroup.Add(1)
+	go func() {
+		defer streamWaitGroup.Done()
+		s.streamOutput(outReader, stdoutHandler)
+	}()
+
+	if errReader != nil {
+		streamWaitGroup.Add(1)
+		go func() {
+			defer streamWaitGroup.Done()
+			s.streamOutput(errReader, func(line string) {
+				stderrTail.add(line)
+				stderrLog.WriteLine(line)
+			})
+		}()
+	}
 
 	err = cmd.Wait()
+	close(exited)
+	run.Killed = <-killed
+	s.setLastRunKilled(run.Killed)
+
+	cancelRun()
+	<-healthDone
+
+	if !waitTimeout(&streamWaitGroup, OUTPUT_DRAIN_TIMEOUT) {
+		log.Printf("output of service '%s' (ID: '%s') is still open after exit, stop reading it", s.Name, s.ID)
+	}
+
 	if ctx.Err() == context.Canceled {
 		return ctx.Err()
 	}
+	if unhealthy.Load() {
+		return errUnhealthy
+	}
+	if err != nil && cgroup != nil && cgroup.oomKilled() {
+		return fmt.Errorf("%w: %v", errOOMKilled, err)
+	}
 	if err != nil {
 		return fmt.Errorf("exit command: %w", err)
 	}
@@ -176,31 +336,47 @@ func (s *service) executeCommand(ctx context.Context) error {
 
 func (s *service) Start(serviceContext context.Context) error {
 	s.mutex.Lock()
-	defer s.mutex.Unlock()
 
-	if s.status == SERVICE_RUNNING {
+	if s.status != SERVICE_STOPPED {
+		s.mutex.Unlock()
 		return fmt.Errorf("service '%s' (ID: '%s') is already running", s.Name, s.ID)
 	}
 
 	ctx, cancel := context.WithCancel(serviceContext)
+	started := make(chan error, 1)
 
 	s.commandWaitGroup.Add(1)
-	go s.executeCommand(ctx)
+	go s.supervise(ctx, started)
 
-	s.status = SERVICE_RUNNING
+	s.changeStatus(SERVICE_RUNNING)
+	s.restartAttempts = 0
 	s.cancelService = cancel
+	s.mutex.Unlock()
+
+	// Wait for the process to be spawned, so that errors like a missing
+	// executable are returned to the caller
+	if err := <-started; err != nil {
+		s.commandWaitGroup.Wait()
+		return err
+	}
 
 	return nil
 }
 
-func (s *service) Stop() error {
+// Stop stops the service and waits for its process to exit. gracePeriod overrides
+// the stop timeout of the service when not nil. It returns true if the process
+// exited by itself within the grace period and false if it had to be killed.
+func (s *service) Stop(gracePeriod *time.Duration) (bool, error) {
 	s.mutex.Lock()
 
 	if s.status == SERVICE_STOPPED {
 		s.mutex.Unlock()
-		return fmt.Errorf("service '%s' (ID: '%s') is not running", s.Name, s.ID)
+		return false, fmt.Errorf("service '%s' (ID: '%s') is not running", s.Name, s.ID)
 	}
 
+	s.stopGracePeriod = gracePeriod
+	s.lastRunKilled = false
+
 	if s.cancelService != nil {
 		s.cancelService()
 	}
@@ -210,10 +386,11 @@ func (s *service) Stop() error {
 	s.commandWaitGroup.Wait()
 
 	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	s.cancelService = nil
-	s.mutex.Unlock()
+	s.stopGracePeriod = nil
 
-	return nil
+	return !s.lastRunKilled, nil
 }
 
 func newService(
@@ -222,8 +399,10 @@ func newService(
 	commandName string,
 	commandArgs []string,
 	executeDirectory string,
-	stdoutHandler func(service *service, line string),
-	stderrHandler func(service *service, line string),
+	config ServiceConfig,
+	openLog func(service *service, streamName string) (logSink, error),
+	statusChanged func(change StatusChange),
+	cgroupSlice string,
 
 ) (*service, error) {
 	if serviceID == "" {
@@ -238,6 +417,64 @@ func newService(
 		return nil, errors.New("command name cannot be empty")
 	}
 
+	if err := config.RestartPolicy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid restart policy: %w", err)
+	}
+
+	if err := config.Stop.validate(); err != nil {
+		return nil, fmt.Errorf("invalid stop spec: %w", err)
+	}
+
+	if err := validateEnv(config.Env); err != nil {
+		return nil, err
+	}
+
+	if err := config.HealthCheck.validate(); err != nil {
+		return nil, fmt.Errorf("invalid health check: %w", err)
+	}
+
+	if err := config.LogRotation.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid log rotation: %w", err)
+	}
+
+	if err := config.LongLines.validate(); err != nil {
+		return nil, fmt.Errorf("invalid long lines: %w", err)
+	}
+
+	if err := config.Multiline.validate(); err != nil {
+		return nil, fmt.Errorf("invalid multiline: %w", err)
+	}
+
+	if err := config.Resources.validate(); err != nil {
+		return nil, fmt.Errorf("invalid resource limits: %w", err)
+	}
+
+	if err := validateRunAs(config); err != nil {
+		return nil, fmt.Errorf("invalid run as: %w", err)
+	}
+
+	if err := config.Sandbox.validate(); err != nil {
+		return nil, fmt.Errorf("invalid sandbox: %w", err)
+	}
+
+	if err := validateRlimits(config.Rlimits); err != nil {
+		return nil, fmt.Errorf("invalid rlimits: %w", err)
+	}
+
+	if err := config.Scheduling.validate(); err != nil {
+		return nil, fmt.Errorf("invalid scheduling: %w", err)
+	}
+
+	if err := validateTTY(config); err != nil {
+		return nil, fmt.Errorf("invalid tty: %w", err)
+	}
+
+	for _, dependency := range config.DependsOn {
+		if err := dependency.validate(); err != nil {
+			return nil, fmt.Errorf("invalid dependency: %w", err)
+		}
+	}
+
 	service := &service{
 		ID:   serviceID,
 		Name: serviceName,
@@ -246,9 +483,11 @@ func newService(
 			Arguments: commandArgs,
 		},
 		ExecuteDirectory: executeDirectory,
+		Config:           config,
 		status:           SERVICE_STOPPED,
-		stdoutHandler:    stdoutHandler,
-		stderrHandler:    stderrHandler,
+		openLog:          openLog,
+		statusChanged:    statusChanged,
+		cgroupSlice:      cgroupSlice,
 	}
 
 	return service, nil
@@ -0,0 +1,86 @@
+package manager
+
+import (
+	"sync"
+	"time"
+)
+
+// Number of status changes a subscriber can lag behind before changes are dropped
+const STATUS_SUBSCRIBER_BUFFER = 64
+
+// StatusChange is a change of the status of a service.
+type StatusChange struct {
+	ServiceID string        `json:"service_id"`
+	Name      string        `json:"name"`
+	Status    ServiceStatus `json:"status"`
+	Time      time.Time     `json:"time"`
+}
+
+// statusBroadcaster delivers the status changes of all services to subscribers.
+type statusBroadcaster struct {
+	subscribers map[chan StatusChange]struct{}
+	mutex       sync.Mutex
+}
+
+func newStatusBroadcaster() *statusBroadcaster {
+	return &statusBroadcaster{
+		subscribers: make(map[chan StatusChange]struct{}),
+	}
+}
+
+// publish sends a change to every subscriber without blocking, a subscriber
+// that fell behind misses it.
+func (b *statusBroadcaster) publish(change StatusChange) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- change:
+		default:
+		}
+	}
+}
+
+// SubscribeStatus returns a channel receiving the status changes of all
+// services and a function ending the subscription. Changes are dropped when
+// the subscriber falls behind.
+func (sm *ServiceManager) SubscribeStatus() (<-chan StatusChange, func()) {
+	b := sm.statusBroadcaster
+	subscriber := make(chan StatusChange, STATUS_SUBSCRIBER_BUFFER)
+
+	b.mutex.Lock()
+	b.subscribers[subscriber] = struct{}{}
+	b.mutex.Unlock()
+
+	unsubscribe := func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+
+		if _, ok := b.subscribers[subscriber]; ok {
+			delete(b.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+
+	return subscriber, unsubscribe
+}
+
+// changeStatus sets the status of the service and reports the change. The
+// mutex of the service must be held.
+func (s *service) changeStatus(status ServiceStatus) {
+	if s.status == status {
+		return
+	}
+
+	s.status = status
+
+	if s.statusChanged != nil {
+		s.statusChanged(StatusChange{
+			ServiceID: s.ID,
+			Name:      s.Name,
+			Status:    status,
+			Time:      time.Now(),
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package manager
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	DEFAULT_STOP_SIGNAL  = "SIGTERM"
+	DEFAULT_STOP_TIMEOUT = 10 * time.Second
+)
+
+func (spec StopSpec) validate() error {
+	if spec.TimeoutSeconds < 0 {
+		return errors.New("stop timeout cannot be negative")
+	}
+
+	return validateStopSignal(spec.signal())
+}
+
+func (spec StopSpec) signal() string {
+	if spec.Signal == "" {
+		return DEFAULT_STOP_SIGNAL
+	}
+	return spec.Signal
+}
+
+func (spec StopSpec) gracePeriod() time.Duration {
+	if spec.TimeoutSeconds == 0 {
+		return DEFAULT_STOP_TIMEOUT
+	}
+	return secondsToDuration(spec.TimeoutSeconds)
+}
+
+// stopParams returns the signal and grace period to use for the current stop,
+// taking the per-call override of Stop into account.
+func (s *service) stopParams() (string, time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	gracePeriod := s.Config.Stop.gracePeriod()
+	if s.stopGracePeriod != nil {
+		gracePeriod = *s.stopGracePeriod
+	}
+
+	return s.Config.Stop.signal(), gracePeriod
+}
+
+func (s *service) setLastRunKilled(killed bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lastRunKilled = killed
+}
@@ -0,0 +1,113 @@
+//go:build linux
+
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/creack/pty"
+)
+
+const (
+	DEFAULT_TTY_ROWS = 24
+	DEFAULT_TTY_COLS = 80
+)
+
+func validateTTY(config ServiceConfig) error {
+	if !config.TTY && config.TTYSize != (TTYSize{}) {
+		return errors.New("tty size requires tty")
+	}
+
+	return nil
+}
+
+func (size TTYSize) winsize() *pty.Winsize {
+	winsize := &pty.Winsize{Rows: size.Rows, Cols: size.Cols}
+
+	if winsize.Rows == 0 {
+		winsize.Rows = DEFAULT_TTY_ROWS
+	}
+	if winsize.Cols == 0 {
+		winsize.Cols = DEFAULT_TTY_COLS
+	}
+
+	return winsize
+}
+
+// openTTY allocates a pseudo-terminal and makes it the standard streams and
+// the controlling terminal of cmd. It returns the side read by the manager and
+// the side of the command, to close once the command is started.
+func openTTY(cmd *exec.Cmd, size TTYSize) (*os.File, *os.File, error) {
+	terminal, commandTerminal, err := pty.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := pty.Setsize(terminal, size.winsize()); err != nil {
+		terminal.Close()
+		commandTerminal.Close()
+		return nil, nil, fmt.Errorf("set window size: %w", err)
+	}
+
+	cmd.Stdin = commandTerminal
+	cmd.Stdout = commandTerminal
+	cmd.Stderr = commandTerminal
+
+	// A terminal is the controlling terminal of a session, so that the command
+	// gets SIGWINCH when it is resized. The session leader also leads its own
+	// process group, it cannot join another one.
+	cmd.SysProcAttr.Setpgid = false
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	// Descriptor of the terminal in the command, its standard input
+	cmd.SysProcAttr.Ctty = 0
+
+	return terminal, commandTerminal, nil
+}
+
+// ttyReader reads the side of a terminal of the manager. Reading fails with
+// EIO instead of returning EOF once the command and its children closed the
+// other side.
+type ttyReader struct {
+	*os.File
+}
+
+func (r ttyReader) Read(p []byte) (int, error) {
+	n, err := r.File.Read(p)
+	if errors.Is(err, syscall.EIO) {
+		return n, io.EOF
+	}
+	return n, err
+}
+
+func (s *service) setTTY(terminal *os.File) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tty = terminal
+}
+
+// ResizeTTY sets the window size of the terminal of the current run. The next
+// runs start with the configured size.
+func (s *service) ResizeTTY(size TTYSize) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.Config.TTY {
+		return fmt.Errorf("service '%s' (ID: '%s') does not run in a terminal", s.Name, s.ID)
+	}
+
+	if s.tty == nil {
+		return fmt.Errorf("service '%s' (ID: '%s') is not running", s.Name, s.ID)
+	}
+
+	if err := pty.Setsize(s.tty, size.winsize()); err != nil {
+		return fmt.Errorf("error resizing terminal of service '%s' (ID: '%s'). error: %v", s.Name, s.ID, err)
+	}
+
+	return nil
+}
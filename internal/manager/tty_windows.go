@@ -0,0 +1,18 @@
+//go:build windows
+
+package manager
+
+import "errors"
+
+func validateTTY(config ServiceConfig) error {
+	if config.TTY || config.TTYSize != (TTYSize{}) {
+		return errors.New("terminals are only supported on Linux")
+	}
+
+	return nil
+}
+
+// ResizeTTY fails on Windows, where services do not run in a terminal.
+func (s *service) ResizeTTY(size TTYSize) error {
+	return errors.New("terminals are only supported on Linux")
+}
@@ -1,11 +1,73 @@
 package manager
 
+import (
+	"service-manager/internal/logstore"
+	"time"
+)
+
 type ServiceStatus string
 
 const (
-	SERVICE_UNKNOWN ServiceStatus = "service_unknown"
-	SERVICE_RUNNING ServiceStatus = "service_running"
-	SERVICE_STOPPED ServiceStatus = "service_stopped"
+	SERVICE_UNKNOWN    ServiceStatus = "service_unknown"
+	SERVICE_RUNNING    ServiceStatus = "service_running"
+	SERVICE_STOPPED    ServiceStatus = "service_stopped"
+	SERVICE_RESTARTING ServiceStatus = "service_restarting"
+	SERVICE_STARTING   ServiceStatus = "service_starting"
+	SERVICE_HEALTHY    ServiceStatus = "service_healthy"
+	SERVICE_UNHEALTHY  ServiceStatus = "service_unhealthy"
+)
+
+// IsActive reports whether the process of the service is alive.
+func (status ServiceStatus) IsActive() bool {
+	switch status {
+	case SERVICE_RUNNING, SERVICE_STARTING, SERVICE_HEALTHY, SERVICE_UNHEALTHY:
+		return true
+	default:
+		return false
+	}
+}
+
+type HealthCheckType string
+
+const (
+	HEALTH_CHECK_HTTP HealthCheckType = "http"
+	HEALTH_CHECK_TCP  HealthCheckType = "tcp"
+	HEALTH_CHECK_EXEC HealthCheckType = "exec"
+)
+
+type RestartMode string
+
+const (
+	RESTART_NEVER      RestartMode = "never"
+	RESTART_ON_FAILURE RestartMode = "on-failure"
+	RESTART_ALWAYS     RestartMode = "always"
+)
+
+type ExitReason string
+
+const (
+	EXIT_USER_STOP    ExitReason = "user_stop"
+	EXIT_CRASH        ExitReason = "crash"
+	EXIT_COMPLETED    ExitReason = "completed"
+	EXIT_START_FAILED ExitReason = "start_failed"
+	EXIT_UNHEALTHY    ExitReason = "unhealthy"
+	EXIT_OOM_KILLED   ExitReason = "oom_killed"
+)
+
+type DependencyCondition string
+
+const (
+	DEPENDENCY_STARTED   DependencyCondition = "started"
+	DEPENDENCY_HEALTHY   DependencyCondition = "healthy"
+	DEPENDENCY_LISTENING DependencyCondition = "listening"
+)
+
+type IOClass string
+
+const (
+	IO_CLASS_REALTIME    IOClass = "realtime"
+	IO_CLASS_BEST_EFFORT IOClass = "best-effort"
+	IO_CLASS_IDLE        IOClass = "idle"
 )
 
 type Command struct {
@@ -15,11 +77,280 @@ type Command struct {
 	Arguments []string `json:"args"`
 }
 
+// RestartPolicy describes what happens when the process of a service exits
+// without being stopped through the manager.
+type RestartPolicy struct {
+	// Mode is one of "never" (default), "on-failure" or "always"
+	Mode RestartMode `json:"mode"`
+	// MaxRetries is the number of consecutive restarts before giving up, 0 means unlimited
+	MaxRetries int `json:"max_retries"`
+	// InitialDelaySeconds is the delay before the first restart, defaults to 1 second
+	InitialDelaySeconds float64 `json:"initial_delay_seconds"`
+	// Multiplier is applied to the delay after every restart, defaults to 2
+	Multiplier float64 `json:"multiplier"`
+	// MaxDelaySeconds caps the delay between restarts, defaults to 60 seconds
+	MaxDelaySeconds float64 `json:"max_delay_seconds"`
+}
+
+// StopSpec describes how the process group of a service is stopped.
+type StopSpec struct {
+	// Signal is sent first, defaults to "SIGTERM". Ignored on Windows
+	Signal string `json:"signal"`
+	// TimeoutSeconds is the grace period before the process group is killed, defaults to 10 seconds
+	TimeoutSeconds float64 `json:"timeout_seconds"`
+}
+
+type LongLineMode string
+
+const (
+	// Long lines are captured as several lines
+	LONG_LINES_SPLIT LongLineMode = "split"
+	// The end of long lines is dropped
+	LONG_LINES_TRUNCATE LongLineMode = "truncate"
+)
+
+// LongLines describes how output lines longer than the maximum are captured.
+// Every part of a split line but the last and every truncated line end with a
+// marker.
+type LongLines struct {
+	// Mode is "split" or "truncate", defaults to "split"
+	Mode LongLineMode `json:"mode"`
+	// MaxBytes is the maximum length of a captured line, defaults to 64 KiB
+	MaxBytes int `json:"max_bytes"`
+}
+
+type MultilinePreset string
+
+const (
+	// Java stack traces
+	MULTILINE_JAVA MultilinePreset = "java"
+	// Python tracebacks
+	MULTILINE_PYTHON MultilinePreset = "python"
+	// Go panics
+	MULTILINE_GO MultilinePreset = "go"
+	// Node.js stack traces and uncaught errors
+	MULTILINE_NODE MultilinePreset = "node"
+)
+
+// Multiline groups consecutive output lines into one record, such as the lines
+// of a stack trace, which is stored and streamed as a single entry. It is
+// disabled unless a preset or a start pattern is set.
+type Multiline struct {
+	// Preset selects built-in rules, "java", "python", "go" or "node"
+	Preset MultilinePreset `json:"preset"`
+	// StartPattern is a regular expression matching the first line of a record,
+	// lines that do not match are appended to the current record
+	StartPattern string `json:"start_pattern"`
+	// FlushTimeoutSeconds is how long a record waits for more lines before it is
+	// captured, defaults to 0.5 seconds
+	FlushTimeoutSeconds float64 `json:"flush_timeout_seconds"`
+	// MaxLines is the maximum number of lines of a record, defaults to 500
+	MaxLines int `json:"max_lines"`
+}
+
+// Sandbox isolates a service with Linux namespaces, Linux only. Namespaces
+// require the manager to run as root.
+type Sandbox struct {
+	// ReadOnlyPaths are absolute paths mounted read-only, with what is mounted
+	// below them, in a mount namespace of the service
+	ReadOnlyPaths []string `json:"read_only_paths"`
+	// PrivatePID runs the service in a PID namespace with its own /proc, in
+	// which only its processes are visible
+	PrivatePID bool `json:"private_pid"`
+	// NoNetwork runs the service in a network namespace with only a loopback
+	// interface
+	NoNetwork bool `json:"no_network"`
+	// NoNewPrivileges stops the service from gaining privileges by executing
+	// setuid programs or programs with file capabilities
+	NoNewPrivileges bool `json:"no_new_privileges"`
+}
+
+// TTYSize is the window size of the terminal of a service, 24 rows and 80
+// columns unless set.
+type TTYSize struct {
+	Rows uint16 `json:"rows"`
+	Cols uint16 `json:"cols"`
+}
+
+// Rlimit is a limit of the service process, inherited by its children. -1
+// means unlimited.
+type Rlimit struct {
+	Soft int64 `json:"soft"`
+	// Hard is the ceiling the process can raise Soft to, defaults to Soft
+	Hard *int64 `json:"hard,omitempty"`
+}
+
+// Scheduling sets the priority of the service process, inherited by its
+// children, Linux only. Unset values are inherited from the manager.
+type Scheduling struct {
+	// Nice is the CPU priority, from -20 (highest) to 19 (lowest)
+	Nice *int `json:"nice,omitempty"`
+	// IOClass is "realtime", "best-effort" or "idle"
+	IOClass IOClass `json:"io_class,omitempty"`
+	// IOPriority is the priority within the realtime and best-effort
+	// classes, from 0 (highest) to 7 (lowest), 4 by default
+	IOPriority *int `json:"io_priority,omitempty"`
+	// CPUAffinity is the list of CPUs the service can run on
+	CPUAffinity []int `json:"cpu_affinity,omitempty"`
+}
+
+// ResourceLimits are enforced by running the service in a cgroup v2 of its
+// own under the manager slice, Linux only. Zero values leave a resource
+// unlimited.
+type ResourceLimits struct {
+	// MemoryMaxBytes is the hard memory limit, processes going over it are OOM killed
+	MemoryMaxBytes int64 `json:"memory_max_bytes"`
+	// MemoryHighBytes is the memory usage above which processes are throttled
+	MemoryHighBytes int64 `json:"memory_high_bytes"`
+	// CPUQuota is the number of CPUs the service can use, such as 0.5 or 2
+	CPUQuota float64 `json:"cpu_quota"`
+	// CPUWeight is the share of CPU time under contention, from 1 to 10000, 100 by default
+	CPUWeight int `json:"cpu_weight"`
+	// PidsMax is the maximum number of processes and threads
+	PidsMax int `json:"pids_max"`
+	// IOWeight is the share of IO time under contention, from 1 to 10000, 100 by default
+	IOWeight int `json:"io_weight"`
+}
+
+// HealthCheck describes how to probe a running service.
+type HealthCheck struct {
+	// Type is "http", "tcp" or "exec", empty disables health checks
+	Type HealthCheckType `json:"type"`
+	// URL is requested with GET by http checks
+	URL string `json:"url"`
+	// ExpectedStatus is the status code expected by http checks, defaults to any 2xx
+	ExpectedStatus int `json:"expected_status"`
+	// Address is the host:port tcp checks connect to
+	Address string `json:"address"`
+	// Command is run in the execute directory with the service environment by exec checks
+	Command Command `json:"command"`
+	// ExpectedExitCode is the exit code expected by exec checks
+	ExpectedExitCode int `json:"expected_exit_code"`
+	// IntervalSeconds is the time between two checks, defaults to 10 seconds
+	IntervalSeconds float64 `json:"interval_seconds"`
+	// TimeoutSeconds is the time a single check may take, defaults to 5 seconds
+	TimeoutSeconds float64 `json:"timeout_seconds"`
+	// FailureThreshold is the number of consecutive failures before the service
+	// is unhealthy, defaults to 3
+	FailureThreshold int `json:"failure_threshold"`
+	// StartPeriodSeconds is the time after start during which failures are not counted
+	StartPeriodSeconds float64 `json:"start_period_seconds"`
+	// RestartOnUnhealthy restarts the process once it is unhealthy
+	RestartOnUnhealthy bool `json:"restart_on_unhealthy"`
+}
+
+// HealthState is the result of the latest health checks of a service.
+type HealthState struct {
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastCheckAt         time.Time `json:"last_check_at"`
+	LastError           string    `json:"last_error,omitempty"`
+}
+
+// Dependency is a service that has to be up before the service that depends on it starts.
+type Dependency struct {
+	ServiceID string `json:"service_id"`
+	// Condition is "started" (default), "healthy" or "listening"
+	Condition DependencyCondition `json:"condition"`
+	// TimeoutSeconds bounds the wait for the condition, defaults to 60 seconds
+	TimeoutSeconds float64 `json:"timeout_seconds"`
+}
+
+// ServiceConfig groups the optional settings of a service.
+type ServiceConfig struct {
+	RestartPolicy RestartPolicy `json:"restart_policy"`
+	Stop          StopSpec      `json:"stop"`
+	// Env holds extra environment variables, values can reference the manager's
+	// environment with ${VAR} or ${VAR:-default}
+	Env map[string]string `json:"env"`
+	// EnvFiles are .env files loaded in order, relative paths are resolved against
+	// the execute directory
+	EnvFiles []string `json:"env_files"`
+	// CleanEnv starts the service from an empty environment instead of the manager's
+	CleanEnv bool `json:"clean_env"`
+	// HealthCheck is disabled when its type is empty
+	HealthCheck HealthCheck  `json:"health_check"`
+	DependsOn   []Dependency `json:"depends_on"`
+	// Autostart starts the service when the manager boots
+	Autostart bool `json:"autostart"`
+	// LogRotation overrides the non-zero limits of the manager's rotation policy
+	LogRotation logstore.RotationPolicy `json:"log_rotation"`
+	LongLines   LongLines               `json:"long_lines"`
+	Multiline   Multiline               `json:"multiline"`
+	Resources   ResourceLimits          `json:"resources"`
+	// RunAsUser is the name or id of the user the service runs as, Linux only
+	RunAsUser string `json:"run_as_user"`
+	// RunAsGroup is the name or id of the group the service runs as, defaults
+	// to the primary group of RunAsUser
+	RunAsGroup string `json:"run_as_group"`
+	// SupplementaryGroups are the names or ids of the other groups of the service,
+	// the groups RunAsUser is a member of when empty
+	SupplementaryGroups []string `json:"supplementary_groups"`
+	// Umask is the octal file mode creation mask of the service, such as "027",
+	// inherited from the manager when empty. Linux only
+	Umask   string  `json:"umask"`
+	Sandbox Sandbox `json:"sandbox"`
+	// Rlimits are keyed by resource name, such as "nofile", "core" or
+	// "nproc". Linux only
+	Rlimits    map[string]Rlimit `json:"rlimits"`
+	Scheduling Scheduling        `json:"scheduling"`
+	// TTY runs the service in a pseudo-terminal instead of with pipes, its
+	// output is logged as stdout. Linux only
+	TTY     bool    `json:"tty"`
+	TTYSize TTYSize `json:"tty_size"`
+}
+
+type RestartState struct {
+	// Attempts is the number of consecutive restarts done so far
+	Attempts int
+	// NextRetryAt is zero when no restart is scheduled
+	NextRetryAt time.Time
+}
+
+// RunRecord describes one run of the process of a service.
+type RunRecord struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	// ExitCode is nil when the process was terminated by a signal or never started
+	ExitCode *int `json:"exit_code"`
+	// Signal is the name of the signal that terminated the process, if any
+	Signal string     `json:"signal,omitempty"`
+	Reason ExitReason `json:"reason"`
+	// Killed is true when the process did not stop within its grace period
+	Killed bool `json:"killed"`
+	// Error is the error that ended the run, if any
+	Error string `json:"error,omitempty"`
+	// StderrTail holds the last lines written to stderr before the exit
+	StderrTail []string `json:"stderr_tail"`
+}
+
+type AutostartResult struct {
+	ServiceID string `json:"service_id"`
+	Name      string `json:"name"`
+	Started   bool   `json:"started"`
+	Error     string `json:"error,omitempty"`
+}
+
+// StartupSummary reports which services were started on boot.
+type StartupSummary struct {
+	StartedAt time.Time `json:"started_at"`
+	// FinishedAt is zero while services are still being started
+	FinishedAt time.Time         `json:"finished_at"`
+	Results    []AutostartResult `json:"results"`
+}
+
+// logSink receives the output lines of one stream of a service run.
+// WriteLine must not block, so that a slow disk cannot stall the process.
+type logSink interface {
+	WriteLine(line string)
+	Close() error
+}
+
 type serviceData struct {
 	ID               string
 	Name             string
 	Cmd              Command
 	ExecuteDirectory string
+	Config           ServiceConfig
 }
 
 type ResourcesData struct {
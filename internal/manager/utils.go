@@ -1,27 +1,11 @@
 package manager
 
 import (
-	"fmt"
-	"os"
-	"path/filepath"
+	"time"
 
 	"github.com/shirou/gopsutil/v3/process"
 )
 
-func openAppend(filePath string) (*os.File, error) {
-	// Flags to open file in append mode, if file is not exist then create and open
-	// in append mode
-	parentDir := filepath.Dir(filePath)
-
-	if err := os.MkdirAll(parentDir, 0755); err != nil {
-		return nil, fmt.Errorf("create parent dir: %w", err)
-	}
-
-	flags := os.O_APPEND | os.O_CREATE | os.O_WRONLY
-
-	return os.OpenFile(filePath, flags, 0644)
-}
-
 // collectNetworkInfoRecursive gets all LISTEN connections for the process and its children
 // and normalizes "::" to "0.0.0.0"
 func collectNetworkInfoRecursive(proc *process.Process) []NetworkInfo {
@@ -55,3 +39,7 @@ func collectNetworkInfoRecursive(proc *process.Process) []NetworkInfo {
 
 	return res
 }
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}